@@ -0,0 +1,274 @@
+// Copyright 2020 Daniel Akiva
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goserv
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/emicklei/go-restful"
+	"github.com/op/go-logging"
+)
+
+const (
+	// AccessLogCombined renders the Apache Combined Log Format, followed by the latency in microseconds
+	AccessLogCombined = "COMBINED"
+	// AccessLogJSON renders each request as a single line JSON object
+	AccessLogJSON = "JSON"
+	// AccessLogECS renders each request as a single line JSON object following the Elastic Common Schema
+	AccessLogECS = "ECS"
+
+	// AccessLogModule is the module of the access log records
+	AccessLogModule = "access"
+
+	combinedTimeFormat = "02/Jan/2006:15:04:05 -0700"
+	ecsVersion         = "1.6.0"
+)
+
+// AccessLogConfig represents configuration of the AccessLogFilter. Access logs are written to their own backends, separate from the application logs and regardless of their levels, and each record is written as is regardless of the formatter of the backend.
+type AccessLogConfig struct {
+	// Format is one of [COMBINED, JSON, ECS], defaulting to COMBINED
+	Format string `json:"format"`
+	// TrustedProxies are the CIDR blocks or addresses of proxies whose X-Forwarded-For header is trusted to report the client address
+	TrustedProxies []string        `json:"trusted_proxies"`
+	Backends       []BackendConfig `json:"backends"`
+}
+
+// Validate ensures the configuration is valid
+func (a *AccessLogConfig) Validate() error {
+	if a.Format != "" && a.Format != AccessLogCombined && a.Format != AccessLogJSON && a.Format != AccessLogECS {
+		return fmt.Errorf("invalid access log format %s, must be one of [COMBINED, JSON, ECS]", a.Format)
+	}
+	if _, err := parseTrustedProxies(a.TrustedProxies); err != nil {
+		return err
+	}
+	if len(a.Backends) == 0 {
+		return errors.New("no access log backends defined")
+	}
+	for _, backend := range a.Backends {
+		if backend.Formatter != "" || backend.Format != "" || backend.LogLevel != "" {
+			return fmt.Errorf("access log backend %s must not set a formatter, format or log level", backend.BackendName)
+		}
+		if err := backend.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// accessLogEntry represents a completed request
+type accessLogEntry struct {
+	start     time.Time
+	duration  time.Duration
+	clientIP  string
+	method    string
+	uri       string
+	path      string
+	query     string
+	protocol  string
+	status    int
+	bytes     int
+	referer   string
+	userAgent string
+	subject   string
+	traceID   string
+}
+
+// AccessLogFilter is middleware that writes an access log record for each request, including the client address, the authenticated subject, the number of bytes written and the latency.
+// The filter should precede the TokenAuthFilter so that the subject of the token is available once the request completes.
+type AccessLogFilter struct {
+	backend        logging.Backend
+	format         string
+	trustedProxies []*net.IPNet
+}
+
+// NewAccessLogFilter initializes a new filter instance from a validated configuration, returning an error if a backend cannot be opened
+func NewAccessLogFilter(config *AccessLogConfig) (*AccessLogFilter, error) {
+	format := config.Format
+	if format == "" {
+		format = AccessLogCombined
+	}
+	trustedProxies, _ := parseTrustedProxies(config.TrustedProxies)
+	backends := []logging.Backend{}
+	async := []*AsyncBackend{}
+	closers := []io.Closer{}
+	for _, b := range config.Backends {
+		backend, asyncBackend, closer, err := b.newBackend(logging.INFO, logging.MustStringFormatter("%{message}"))
+		if err != nil {
+			closeAsyncBackends(async)
+			closeAll(closers)
+			return nil, err
+		}
+		if asyncBackend != nil {
+			async = append(async, asyncBackend)
+		}
+		if closer != nil {
+			closers = append(closers, closer)
+		}
+		backends = append(backends, backend)
+	}
+	setAsyncBackends(accessAsyncBackends, async, closers, DefaultAsyncCloseTimeout)
+	return &AccessLogFilter{backend: logging.MultiLogger(backends...), format: format, trustedProxies: trustedProxies}, nil
+}
+
+// Filter is a filter function that writes an access log record once the request is processed
+func (a *AccessLogFilter) Filter(request *restful.Request, response *restful.Response, chain *restful.FilterChain) {
+	start := time.Now()
+	chain.ProcessFilter(request, response)
+	entry := &accessLogEntry{
+		start:     start,
+		duration:  time.Since(start),
+		clientIP:  forwardedClientIP(request.Request, a.trustedProxies),
+		method:    request.Request.Method,
+		uri:       request.Request.RequestURI,
+		path:      request.Request.URL.Path,
+		query:     request.Request.URL.RawQuery,
+		protocol:  request.Request.Proto,
+		status:    response.StatusCode(),
+		bytes:     response.ContentLength(),
+		referer:   request.Request.Referer(),
+		userAgent: request.Request.UserAgent(),
+	}
+	if entry.uri == "" {
+		entry.uri = request.Request.URL.RequestURI()
+	}
+	if subject, ok := request.Attribute(TokenSubjectAttribute).(string); ok {
+		entry.subject = subject
+	}
+	if traceID := request.Attribute(TraceIDAttribute); traceID != nil {
+		entry.traceID = fmt.Sprint(traceID)
+	}
+	a.log(a.render(entry))
+}
+
+// log writes a line to the backends directly, as a logging.Logger skips records below the level of the application logs
+func (a *AccessLogFilter) log(line string) {
+	record := &logging.Record{Time: time.Now(), Module: AccessLogModule, Level: logging.INFO, Args: []interface{}{line}}
+	a.backend.Log(logging.INFO, 2, record)
+}
+
+func (a *AccessLogFilter) render(entry *accessLogEntry) string {
+	switch a.format {
+	case AccessLogJSON:
+		return marshalAccessLog(entry.jsonFields())
+	case AccessLogECS:
+		return marshalAccessLog(entry.ecsFields())
+	}
+	return entry.combined()
+}
+
+// combined renders the entry in the Apache Combined Log Format, followed by the latency in microseconds (%D)
+func (e *accessLogEntry) combined() string {
+	bytes := "-"
+	if e.bytes > 0 {
+		bytes = strconv.Itoa(e.bytes)
+	}
+	return fmt.Sprintf("%s - %s [%s] \"%s %s %s\" %d %s \"%s\" \"%s\" %d",
+		orDash(e.clientIP),
+		orDash(e.subject),
+		e.start.Format(combinedTimeFormat),
+		e.method,
+		escapeQuotes(e.uri),
+		e.protocol,
+		e.status,
+		bytes,
+		escapeQuotes(orDash(e.referer)),
+		escapeQuotes(orDash(e.userAgent)),
+		e.duration.Microseconds())
+}
+
+func (e *accessLogEntry) jsonFields() map[string]interface{} {
+	fields := map[string]interface{}{
+		"timestamp":   e.start.UTC().Format(time.RFC3339Nano),
+		"remote_addr": e.clientIP,
+		"method":      e.method,
+		"uri":         e.uri,
+		"path":        e.path,
+		"protocol":    e.protocol,
+		"status":      e.status,
+		"bytes":       e.bytes,
+		"duration_ms": durationMillis(e.duration),
+	}
+	optional := map[string]string{"query": e.query, "referer": e.referer, "user_agent": e.userAgent, "subject": e.subject, "trace_id": e.traceID}
+	for key, value := range optional {
+		if value != "" {
+			fields[key] = value
+		}
+	}
+	return fields
+}
+
+func (e *accessLogEntry) ecsFields() map[string]interface{} {
+	request := map[string]interface{}{"method": e.method}
+	if e.referer != "" {
+		request["referrer"] = e.referer
+	}
+	url := map[string]interface{}{"original": e.uri, "path": e.path}
+	if e.query != "" {
+		url["query"] = e.query
+	}
+	fields := map[string]interface{}{
+		"@timestamp": e.start.UTC().Format(time.RFC3339Nano),
+		"ecs":        map[string]interface{}{"version": ecsVersion},
+		"event": map[string]interface{}{
+			"kind":     "event",
+			"category": []string{"web"},
+			"dataset":  "access",
+			"duration": e.duration.Nanoseconds(),
+		},
+		"client": map[string]interface{}{"ip": e.clientIP},
+		"http": map[string]interface{}{
+			"version":  strings.TrimPrefix(e.protocol, "HTTP/"),
+			"request":  request,
+			"response": map[string]interface{}{"status_code": e.status, "body": map[string]interface{}{"bytes": e.bytes}},
+		},
+		"url": url,
+	}
+	if e.userAgent != "" {
+		fields["user_agent"] = map[string]interface{}{"original": e.userAgent}
+	}
+	if e.subject != "" {
+		fields["user"] = map[string]interface{}{"name": e.subject}
+	}
+	if e.traceID != "" {
+		fields["trace"] = map[string]interface{}{"id": e.traceID}
+	}
+	return fields
+}
+
+func marshalAccessLog(fields map[string]interface{}) string {
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return fmt.Sprintf(`{"error":%q}`, err.Error())
+	}
+	return string(data)
+}
+
+func orDash(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+func escapeQuotes(value string) string {
+	return strings.Replace(value, `"`, `\"`, -1)
+}
@@ -0,0 +1,170 @@
+// Copyright 2020 Daniel Akiva
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goserv
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/emicklei/go-restful"
+	"github.com/op/go-logging"
+	"github.com/stretchr/testify/assert"
+)
+
+// serveAccessLog serves a single request through an access log filter writing to a temporary file, returning the access log line
+func serveAccessLog(t *testing.T, format string) string {
+	dir, _ := ioutil.TempDir("", "access")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "access.log")
+	config := &AccessLogConfig{
+		Format:         format,
+		TrustedProxies: []string{"10.0.0.0/8"},
+		Backends:       []BackendConfig{BackendConfig{BackendName: "FILE", FilePath: path}},
+	}
+	assert.NoError(t, config.Validate())
+	filter, err := NewAccessLogFilter(config)
+	assert.NoError(t, err)
+
+	ws := new(restful.WebService)
+	ws.Route(ws.GET("/orders").To(func(request *restful.Request, response *restful.Response) {
+		response.Write([]byte("hello"))
+	}))
+	container := restful.NewContainer()
+	container.Filter(filter.Filter)
+	container.Filter(func(request *restful.Request, response *restful.Response, chain *restful.FilterChain) {
+		request.SetAttribute(TokenSubjectAttribute, "ann")
+		chain.ProcessFilter(request, response)
+	})
+	container.Add(ws)
+	req := httptest.NewRequest(http.MethodGet, "/orders?page=2", nil)
+	req.RemoteAddr = "10.0.0.1:4321"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7, 10.0.0.2")
+	req.Header.Set("User-Agent", "curl/7.68.0")
+	req.Header.Set("Referer", "http://example.com/")
+	container.ServeHTTP(httptest.NewRecorder(), req)
+
+	data, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	return strings.TrimSpace(string(data))
+}
+
+func TestAccessLogCombined(t *testing.T) {
+	// when
+	line := serveAccessLog(t, AccessLogCombined)
+
+	// then
+	pattern := regexp.MustCompile(`^203\.0\.113\.7 - ann \[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] "GET /orders\?page=2 HTTP/1\.1" 200 5 "http://example\.com/" "curl/7\.68\.0" \d+$`)
+	assert.Regexp(t, pattern, line)
+}
+
+func TestAccessLogIgnoresApplicationLevels(t *testing.T) {
+	// given
+	dir, _ := ioutil.TempDir("", "logging")
+	defer os.RemoveAll(dir)
+	defer logging.SetBackend(logging.NewLogBackend(os.Stderr, "", 0))
+	defer setLogLevels(nil)
+	config := &LoggingConfig{
+		LogLevel: "WARNING",
+		Backends: []BackendConfig{BackendConfig{BackendName: "FILE", FilePath: filepath.Join(dir, "app.log")}},
+	}
+	assert.NoError(t, config.InitializeLogging())
+	assert.NoError(t, SetLogLevel(AccessLogModule, logging.ERROR, 0))
+
+	// when
+	line := serveAccessLog(t, AccessLogCombined)
+
+	// then
+	assert.Contains(t, line, `"GET /orders?page=2 HTTP/1.1" 200 5`)
+	app, _ := ioutil.ReadFile(filepath.Join(dir, "app.log"))
+	assert.Empty(t, string(app))
+}
+
+func TestAccessLogJSON(t *testing.T) {
+	// when
+	line := serveAccessLog(t, AccessLogJSON)
+
+	// then
+	entry := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal([]byte(line), &entry))
+	assert.Equal(t, "203.0.113.7", entry["remote_addr"])
+	assert.Equal(t, "GET", entry["method"])
+	assert.Equal(t, "/orders?page=2", entry["uri"])
+	assert.Equal(t, "page=2", entry["query"])
+	assert.Equal(t, float64(200), entry["status"])
+	assert.Equal(t, float64(5), entry["bytes"])
+	assert.Equal(t, "curl/7.68.0", entry["user_agent"])
+	assert.Equal(t, "ann", entry["subject"])
+	assert.Contains(t, entry, "duration_ms")
+}
+
+func TestAccessLogECS(t *testing.T) {
+	// when
+	line := serveAccessLog(t, AccessLogECS)
+
+	// then
+	entry := struct {
+		Client struct {
+			IP string `json:"ip"`
+		} `json:"client"`
+		HTTP struct {
+			Version  string `json:"version"`
+			Response struct {
+				StatusCode int `json:"status_code"`
+				Body       struct {
+					Bytes int `json:"bytes"`
+				} `json:"body"`
+			} `json:"response"`
+		} `json:"http"`
+		URL struct {
+			Path string `json:"path"`
+		} `json:"url"`
+		User struct {
+			Name string `json:"name"`
+		} `json:"user"`
+		Event struct {
+			Duration int64 `json:"duration"`
+		} `json:"event"`
+	}{}
+	assert.NoError(t, json.Unmarshal([]byte(line), &entry))
+	assert.Equal(t, "203.0.113.7", entry.Client.IP)
+	assert.Equal(t, "1.1", entry.HTTP.Version)
+	assert.Equal(t, 200, entry.HTTP.Response.StatusCode)
+	assert.Equal(t, 5, entry.HTTP.Response.Body.Bytes)
+	assert.Equal(t, "/orders", entry.URL.Path)
+	assert.Equal(t, "ann", entry.User.Name)
+	assert.True(t, entry.Event.Duration > 0)
+}
+
+func TestInvalidAccessLogConfig(t *testing.T) {
+	for _, config := range []*AccessLogConfig{
+		&AccessLogConfig{Format: "W3C", Backends: []BackendConfig{BackendConfig{BackendName: "STDOUT"}}},
+		&AccessLogConfig{TrustedProxies: []string{"10.0.0.0/33"}, Backends: []BackendConfig{BackendConfig{BackendName: "STDOUT"}}},
+		&AccessLogConfig{},
+		&AccessLogConfig{Backends: []BackendConfig{BackendConfig{BackendName: "STDOUT", Formatter: FormatterJSON}}},
+	} {
+		// when
+		err := config.Validate()
+
+		// then
+		assert.Error(t, err)
+	}
+}
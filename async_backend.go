@@ -0,0 +1,338 @@
+// Copyright 2020 Daniel Akiva
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goserv
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/op/go-logging"
+)
+
+const (
+	// OverflowBlock blocks the logging caller until the queue has room
+	OverflowBlock = "BLOCK"
+	// OverflowDropOldest drops the oldest queued record to make room
+	OverflowDropOldest = "DROP_OLDEST"
+	// OverflowDropBelowLevel drops records less severe than the drop level, and blocks for others
+	OverflowDropBelowLevel = "DROP_BELOW_LEVEL"
+
+	// DefaultAsyncQueueSize is the default number of records queued by an asynchronous backend
+	DefaultAsyncQueueSize = 1024
+	// DefaultAsyncCloseTimeout is the maximum duration to wait for the queue of a replaced backend to be written
+	DefaultAsyncCloseTimeout = 5 * time.Second
+)
+
+const (
+	loggingAsyncBackends = "logging"
+	accessAsyncBackends  = "access"
+)
+
+var (
+	asyncBackendsLock sync.Mutex
+	// asyncBackends holds the asynchronous backends by owner, ie the logging configuration or the access log
+	asyncBackends = map[string][]*AsyncBackend{}
+	// backendClosers holds the files and connections opened by the backends of each owner
+	backendClosers = map[string][]io.Closer{}
+)
+
+// AsyncConfig represents settings of a backend that writes records asynchronously, so that a slow backend does not slow down the logging caller.
+type AsyncConfig struct {
+	// QueueSize is the number of records queued before the overflow policy applies, defaulting to 1024
+	QueueSize int `json:"queue_size"`
+	// OverflowPolicy is one of [BLOCK, DROP_OLDEST, DROP_BELOW_LEVEL], defaulting to BLOCK
+	OverflowPolicy string `json:"overflow_policy"`
+	// DropLevel is the level below which records are dropped by the DROP_BELOW_LEVEL policy
+	DropLevel string `json:"drop_level"`
+}
+
+// Validate ensures the configuration is valid
+func (a *AsyncConfig) Validate() error {
+	if a.QueueSize < 0 {
+		return errors.New("queue size must not be negative")
+	}
+	switch a.OverflowPolicy {
+	case "", OverflowBlock, OverflowDropOldest:
+		if a.DropLevel != "" {
+			return errors.New("a drop level is only supported by the DROP_BELOW_LEVEL overflow policy")
+		}
+	case OverflowDropBelowLevel:
+		if _, err := logging.LogLevel(a.DropLevel); err != nil {
+			return fmt.Errorf("invalid drop level %s", a.DropLevel)
+		}
+	default:
+		return fmt.Errorf("invalid overflow policy %s, must be one of [BLOCK, DROP_OLDEST, DROP_BELOW_LEVEL]", a.OverflowPolicy)
+	}
+	return nil
+}
+
+// AsyncBackendStats represents the queue and drop counters of an asynchronous backend, exposed as an API.
+type AsyncBackendStats struct {
+	Backend        string            `json:"backend" description:"The name of the backend."`
+	Queued         int               `json:"queued" description:"The number of records waiting to be written."`
+	Capacity       int               `json:"capacity" description:"The maximum number of queued records."`
+	Dropped        uint64            `json:"dropped" description:"The total number of records dropped because the queue was full."`
+	DroppedByLevel map[string]uint64 `json:"dropped_by_level" description:"The number of dropped records per level."`
+}
+
+// asyncRecord is a queued record, or a flush marker if flushed is set
+type asyncRecord struct {
+	level   logging.Level
+	record  *logging.Record
+	flushed chan struct{}
+}
+
+// AsyncBackend is an op/go-logging backend that queues records and writes them to another backend from a single goroutine.
+// Records are formatted before they are queued, so the wrapped backend must not apply a formatter of its own; wrap the AsyncBackend instead.
+type AsyncBackend struct {
+	name      string
+	backend   logging.Backend
+	policy    string
+	dropLevel logging.Level
+	queue     chan asyncRecord
+	lock      sync.RWMutex
+	closed    bool
+	done      chan struct{}
+	dropped   [logging.DEBUG + 1]uint64
+}
+
+// NewAsyncBackend initializes a new backend writing to a backend, starting the goroutine that writes queued records
+func NewAsyncBackend(name string, backend logging.Backend, config *AsyncConfig) *AsyncBackend {
+	size := config.QueueSize
+	if size == 0 {
+		size = DefaultAsyncQueueSize
+	}
+	policy := config.OverflowPolicy
+	if policy == "" {
+		policy = OverflowBlock
+	}
+	dropLevel, _ := logging.LogLevel(config.DropLevel)
+	a := &AsyncBackend{
+		name:      name,
+		backend:   backend,
+		policy:    policy,
+		dropLevel: dropLevel,
+		queue:     make(chan asyncRecord, size),
+		done:      make(chan struct{}),
+	}
+	go a.run()
+	return a
+}
+
+// Log formats a record and queues it, applying the overflow policy if the queue is full. Once the backend is closed records are written synchronously.
+func (a *AsyncBackend) Log(level logging.Level, calldepth int, record *logging.Record) error {
+	// format on the calling goroutine, as formatters resolve the file of the caller from the stack
+	record.Formatted(calldepth + 1)
+	queued := *record
+	a.lock.RLock()
+	defer a.lock.RUnlock()
+	if a.closed {
+		return a.backend.Log(level, calldepth+1, record)
+	}
+	a.enqueue(asyncRecord{level: level, record: &queued})
+	return nil
+}
+
+// Flush waits until all records queued so far are written, or the timeout elapses
+func (a *AsyncBackend) Flush(timeout time.Duration) error {
+	a.lock.RLock()
+	if a.closed {
+		a.lock.RUnlock()
+		return nil
+	}
+	flushed := make(chan struct{})
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case a.queue <- asyncRecord{flushed: flushed}:
+		a.lock.RUnlock()
+	case <-timer.C:
+		a.lock.RUnlock()
+		return fmt.Errorf("timed out flushing %s log backend", a.name)
+	}
+	select {
+	case <-flushed:
+		return nil
+	case <-timer.C:
+		return fmt.Errorf("timed out flushing %s log backend", a.name)
+	}
+}
+
+// Close writes the queued records, waiting at most timeout, and stops the writing goroutine
+func (a *AsyncBackend) Close(timeout time.Duration) error {
+	a.lock.Lock()
+	if a.closed {
+		a.lock.Unlock()
+		return nil
+	}
+	a.closed = true
+	close(a.queue)
+	a.lock.Unlock()
+	select {
+	case <-a.done:
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("timed out closing %s log backend", a.name)
+	}
+}
+
+// Dropped returns the number of records dropped because the queue was full
+func (a *AsyncBackend) Dropped() uint64 {
+	var total uint64
+	for i := range a.dropped {
+		total += atomic.LoadUint64(&a.dropped[i])
+	}
+	return total
+}
+
+// Stats returns the queue and drop counters of the backend
+func (a *AsyncBackend) Stats() AsyncBackendStats {
+	stats := AsyncBackendStats{Backend: a.name, Queued: len(a.queue), Capacity: cap(a.queue), DroppedByLevel: map[string]uint64{}}
+	for i := range a.dropped {
+		if dropped := atomic.LoadUint64(&a.dropped[i]); dropped > 0 {
+			stats.DroppedByLevel[logging.Level(i).String()] = dropped
+			stats.Dropped += dropped
+		}
+	}
+	return stats
+}
+
+func (a *AsyncBackend) enqueue(item asyncRecord) {
+	select {
+	case a.queue <- item:
+		return
+	default:
+	}
+	switch a.policy {
+	case OverflowDropOldest:
+		for {
+			select {
+			case a.queue <- item:
+				return
+			default:
+			}
+			select {
+			case oldest := <-a.queue:
+				if oldest.flushed != nil {
+					close(oldest.flushed)
+				} else {
+					atomic.AddUint64(&a.dropped[oldest.level], 1)
+				}
+			default:
+			}
+		}
+	case OverflowDropBelowLevel:
+		if item.level > a.dropLevel {
+			atomic.AddUint64(&a.dropped[item.level], 1)
+			return
+		}
+	}
+	a.queue <- item
+}
+
+func (a *AsyncBackend) run() {
+	defer close(a.done)
+	for item := range a.queue {
+		if item.flushed != nil {
+			close(item.flushed)
+			continue
+		}
+		a.backend.Log(item.level, 0, item.record)
+	}
+}
+
+// flusher is implemented by backends buffering records, ie the RemoteSyslogBackend
+type flusher interface {
+	Flush(timeout time.Duration) error
+}
+
+// FlushLogging waits until the asynchronous backends configured by InitializeLogging and NewAccessLogFilter have written their queued records, and the remote syslog backends have sent their buffered records, typically on graceful shutdown
+func FlushLogging(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for _, backend := range currentAsyncBackends() {
+		if err := backend.Flush(time.Until(deadline)); err != nil {
+			return err
+		}
+	}
+	for _, closer := range currentBackendClosers() {
+		if backend, ok := closer.(flusher); ok {
+			if err := backend.Flush(time.Until(deadline)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// LoggingStats returns the queue and drop counters of the asynchronous backends configured by InitializeLogging and NewAccessLogFilter
+func LoggingStats() []AsyncBackendStats {
+	backends := currentAsyncBackends()
+	stats := make([]AsyncBackendStats, len(backends))
+	for i, backend := range backends {
+		stats[i] = backend.Stats()
+	}
+	return stats
+}
+
+// setAsyncBackends replaces the asynchronous backends of an owner and the files and connections opened by its backends, closing the previous ones once their queued records are written
+func setAsyncBackends(owner string, backends []*AsyncBackend, closers []io.Closer, timeout time.Duration) {
+	asyncBackendsLock.Lock()
+	previous := asyncBackends[owner]
+	previousClosers := backendClosers[owner]
+	asyncBackends[owner] = backends
+	backendClosers[owner] = closers
+	asyncBackendsLock.Unlock()
+	for _, backend := range previous {
+		backend.Close(timeout)
+	}
+	closeAll(previousClosers)
+}
+
+// closeAsyncBackends closes the asynchronous backends of a configuration that failed to initialize
+func closeAsyncBackends(backends []*AsyncBackend) {
+	for _, backend := range backends {
+		backend.Close(DefaultAsyncCloseTimeout)
+	}
+}
+
+func closeAll(closers []io.Closer) {
+	for _, closer := range closers {
+		closer.Close()
+	}
+}
+
+func currentAsyncBackends() []*AsyncBackend {
+	asyncBackendsLock.Lock()
+	defer asyncBackendsLock.Unlock()
+	backends := []*AsyncBackend{}
+	for _, owner := range []string{loggingAsyncBackends, accessAsyncBackends} {
+		backends = append(backends, asyncBackends[owner]...)
+	}
+	return backends
+}
+
+func currentBackendClosers() []io.Closer {
+	asyncBackendsLock.Lock()
+	defer asyncBackendsLock.Unlock()
+	closers := []io.Closer{}
+	for _, owner := range []string{loggingAsyncBackends, accessAsyncBackends} {
+		closers = append(closers, backendClosers[owner]...)
+	}
+	return closers
+}
@@ -0,0 +1,162 @@
+// Copyright 2020 Daniel Akiva
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goserv
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/op/go-logging"
+	"github.com/stretchr/testify/assert"
+)
+
+// gatedBackend records formatted messages, blocking each write until the gate is opened
+type gatedBackend struct {
+	lock     sync.Mutex
+	messages []string
+	entered  chan struct{}
+	gate     chan struct{}
+}
+
+func newGatedBackend(open bool) *gatedBackend {
+	backend := &gatedBackend{entered: make(chan struct{}, 100), gate: make(chan struct{})}
+	if open {
+		close(backend.gate)
+	}
+	return backend
+}
+
+func (g *gatedBackend) Log(level logging.Level, calldepth int, record *logging.Record) error {
+	g.entered <- struct{}{}
+	<-g.gate
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	g.messages = append(g.messages, record.Formatted(calldepth+1))
+	return nil
+}
+
+func (g *gatedBackend) written() []string {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+	return append([]string{}, g.messages...)
+}
+
+func newAsyncTestLogger(backend *AsyncBackend) *logging.Logger {
+	leveled := logging.AddModuleLevel(logging.NewBackendFormatter(backend, logging.MustStringFormatter("%{shortfile} %{message}")))
+	logger := logging.MustGetLogger("async_test")
+	logger.SetBackend(leveled)
+	return logger
+}
+
+func TestAsyncBackendFlush(t *testing.T) {
+	// given
+	gated := newGatedBackend(true)
+	backend := NewAsyncBackend("FILE", gated, &AsyncConfig{})
+	defer backend.Close(time.Second)
+	logger := newAsyncTestLogger(backend)
+
+	// when
+	logger.Info("first")
+	logger.Info("second")
+	err := backend.Flush(time.Second)
+
+	// then
+	assert.NoError(t, err)
+	messages := gated.written()
+	assert.Len(t, messages, 2)
+	assert.True(t, strings.HasPrefix(messages[0], "async_backend_test.go:"))
+	assert.True(t, strings.HasSuffix(messages[0], " first"))
+	assert.True(t, strings.HasSuffix(messages[1], " second"))
+}
+
+func TestAsyncBackendDropOldest(t *testing.T) {
+	// given
+	gated := newGatedBackend(false)
+	backend := NewAsyncBackend("FILE", gated, &AsyncConfig{QueueSize: 2, OverflowPolicy: OverflowDropOldest})
+	logger := newAsyncTestLogger(backend)
+	logger.Info("0")
+	<-gated.entered
+
+	// when
+	for _, message := range []string{"1", "2", "3", "4"} {
+		logger.Info(message)
+	}
+	close(gated.gate)
+	assert.NoError(t, backend.Close(time.Second))
+
+	// then
+	messages := gated.written()
+	assert.Len(t, messages, 3)
+	assert.True(t, strings.HasSuffix(messages[1], " 3"))
+	assert.True(t, strings.HasSuffix(messages[2], " 4"))
+	assert.Equal(t, uint64(2), backend.Dropped())
+	assert.Equal(t, map[string]uint64{"INFO": 2}, backend.Stats().DroppedByLevel)
+}
+
+func TestAsyncBackendDropBelowLevel(t *testing.T) {
+	// given
+	gated := newGatedBackend(false)
+	backend := NewAsyncBackend("FILE", gated, &AsyncConfig{QueueSize: 1, OverflowPolicy: OverflowDropBelowLevel, DropLevel: "WARNING"})
+	logger := newAsyncTestLogger(backend)
+	logger.Info("0")
+	<-gated.entered
+
+	// when
+	logger.Info("queued")
+	logger.Debug("dropped")
+	logger.Info("dropped")
+	stats := backend.Stats()
+	close(gated.gate)
+	backend.Close(time.Second)
+
+	// then
+	assert.Equal(t, 1, stats.Queued)
+	assert.Equal(t, uint64(2), stats.Dropped)
+	assert.Equal(t, map[string]uint64{"INFO": 1, "DEBUG": 1}, stats.DroppedByLevel)
+	assert.Len(t, gated.written(), 2)
+}
+
+func TestAsyncBackendClosed(t *testing.T) {
+	// given
+	gated := newGatedBackend(true)
+	backend := NewAsyncBackend("FILE", gated, &AsyncConfig{})
+	logger := newAsyncTestLogger(backend)
+	assert.NoError(t, backend.Close(time.Second))
+
+	// when
+	logger.Info("synchronous")
+
+	// then
+	messages := gated.written()
+	assert.Len(t, messages, 1)
+	assert.True(t, strings.HasPrefix(messages[0], "async_backend_test.go:"))
+}
+
+func TestInvalidAsyncConfig(t *testing.T) {
+	for _, config := range []*AsyncConfig{
+		&AsyncConfig{QueueSize: -1},
+		&AsyncConfig{OverflowPolicy: "DROP_NEWEST"},
+		&AsyncConfig{OverflowPolicy: OverflowDropBelowLevel},
+		&AsyncConfig{OverflowPolicy: OverflowBlock, DropLevel: "INFO"},
+	} {
+		// when
+		err := config.Validate()
+
+		// then
+		assert.Error(t, err)
+	}
+}
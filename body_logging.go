@@ -0,0 +1,321 @@
+// Copyright 2020 Daniel Akiva
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goserv
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/emicklei/go-restful"
+)
+
+const (
+	// DefaultBodyLogMaxSize is the default number of body bytes captured for logging
+	DefaultBodyLogMaxSize = 4096
+
+	// BodyLoggingMetadata is the route metadata key of the body logging configuration of a route, see LogBodies
+	BodyLoggingMetadata = "goserv.body_logging"
+
+	redactedValue = "[redacted]"
+)
+
+var (
+	// DefaultRedactedHeaders are the headers whose values are never logged
+	DefaultRedactedHeaders = []string{authorizationHeader, "Cookie", "Set-Cookie", "X-Api-Key"}
+	// DefaultBodyLogContentTypes is the default allowlist of content types whose bodies are logged
+	DefaultBodyLogContentTypes = []string{
+		"application/json",
+		"application/problem+json",
+		"application/x-www-form-urlencoded",
+		"text/*",
+	}
+)
+
+// BodyLoggingConfig represents configuration of request and response body logging by the RestfulLoggingFilter, along with the redaction of headers, query parameters and body fields.
+// Redacted fields are JSON member names matched at any depth, ie password, or dotted paths matched from the root of the document, ie customer.ssn, where arrays are traversed transparently.
+// Redacted fields also apply to form bodies. JSON bodies that are truncated by the max body size are withheld when fields are redacted, as they cannot be redacted reliably.
+type BodyLoggingConfig struct {
+	LogRequestBody  bool `json:"log_request_body"`
+	LogResponseBody bool `json:"log_response_body"`
+	// MaxBodySize is the number of body bytes logged, defaulting to 4096
+	MaxBodySize int `json:"max_body_size"`
+	// ContentTypes is the allowlist of content types whose bodies are logged, ie application/json or text/*
+	ContentTypes []string `json:"content_types"`
+	// RedactHeaders are redacted in addition to the DefaultRedactedHeaders
+	RedactHeaders     []string `json:"redact_headers"`
+	RedactQueryParams []string `json:"redact_query_params"`
+	RedactFields      []string `json:"redact_fields"`
+}
+
+// Validate ensures the configuration is valid
+func (b *BodyLoggingConfig) Validate() error {
+	if b.MaxBodySize < 0 {
+		return errors.New("body logging max body size must not be negative")
+	}
+	for _, contentType := range b.ContentTypes {
+		if !strings.Contains(contentType, "/") {
+			return errors.New("invalid body logging content type " + contentType)
+		}
+	}
+	for _, field := range b.RedactFields {
+		for _, segment := range strings.Split(field, ".") {
+			if segment == "" {
+				return errors.New("invalid redacted field " + field)
+			}
+		}
+	}
+	return nil
+}
+
+// LogBodies overrides the body logging configuration of the RestfulLoggingFilter for a route, storing the configuration as route metadata, ie
+//
+//	ws.Route(goserv.LogBodies(ws.POST("/signup").To(signup), &goserv.BodyLoggingConfig{LogRequestBody: true, RedactFields: []string{"password"}}))
+//
+// A configuration logging neither body disables body logging for the route. The redacted headers and query parameters of the configuration also apply to the request and response lines.
+// The RestfulLoggingFilter resolves the route before processing the request, see RestfulLoggingFilter.SetContainer.
+func LogBodies(builder *restful.RouteBuilder, config *BodyLoggingConfig) *restful.RouteBuilder {
+	return builder.Metadata(BodyLoggingMetadata, config)
+}
+
+// bodyCapture holds the bodies of a request and its response captured for logging
+type bodyCapture struct {
+	config           *BodyLoggingConfig
+	requestBody      []byte
+	requestTruncated bool
+	writer           *capturingResponseWriter
+}
+
+// captureBodies captures the bodies enabled by the configuration
+func captureBodies(request *restful.Request, response *restful.Response, config *BodyLoggingConfig) *bodyCapture {
+	capture := &bodyCapture{config: config}
+	if config.LogRequestBody && request.Request.Body != nil && request.Request.Body != http.NoBody {
+		body := request.Request.Body
+		prefix, err := ioutil.ReadAll(io.LimitReader(body, int64(config.maxBodySize())+1))
+		var rest io.Reader = body
+		if err != nil {
+			rest = &failedReader{err: err}
+		}
+		request.Request.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(prefix), rest), body}
+		if len(prefix) > config.maxBodySize() {
+			prefix, capture.requestTruncated = prefix[:config.maxBodySize()], true
+		}
+		capture.requestBody = prefix
+	}
+	if config.LogResponseBody {
+		capture.writer = &capturingResponseWriter{ResponseWriter: response.ResponseWriter, max: config.maxBodySize()}
+		response.ResponseWriter = capture.writer
+	}
+	return capture
+}
+
+// restore reinstates the response writer replaced by the capture
+func (b *bodyCapture) restore(response *restful.Response) {
+	if b.writer != nil {
+		response.ResponseWriter = b.writer.ResponseWriter
+	}
+}
+
+// loggedRequestBody returns the request body as logged, or false if the request body is not logged
+func (b *bodyCapture) loggedRequestBody(request *restful.Request) (string, bool) {
+	if !b.config.LogRequestBody || b.requestBody == nil {
+		return "", false
+	}
+	return b.config.loggedBody(request.Request.Header, b.requestBody, b.requestTruncated), true
+}
+
+// loggedResponseBody returns the response body as logged, or false if the response body is not logged
+func (b *bodyCapture) loggedResponseBody(response *restful.Response) (string, bool) {
+	if b.writer == nil {
+		return "", false
+	}
+	return b.config.loggedBody(response.Header(), b.writer.body.Bytes(), b.writer.truncated), true
+}
+
+func (b *BodyLoggingConfig) maxBodySize() int {
+	if b.MaxBodySize == 0 {
+		return DefaultBodyLogMaxSize
+	}
+	return b.MaxBodySize
+}
+
+// loggedBody renders a body for logging, redacting configured fields of JSON and form bodies
+func (b *BodyLoggingConfig) loggedBody(header http.Header, body []byte, truncated bool) string {
+	contentType := header.Get("Content-Type")
+	allowed := b.ContentTypes
+	if len(allowed) == 0 {
+		allowed = DefaultBodyLogContentTypes
+	}
+	if encoding := header.Get("Content-Encoding"); encoding != "" && encoding != "identity" {
+		return fmt.Sprintf("[%d bytes %s encoded]", len(body), encoding)
+	}
+	if !matchContentType(allowed, contentType) {
+		return fmt.Sprintf("[%d bytes %s]", len(body), contentType)
+	}
+	suffix := ""
+	if truncated {
+		suffix = "...[truncated]"
+	}
+	if len(b.RedactFields) == 0 || len(body) == 0 {
+		return string(body) + suffix
+	}
+	switch {
+	case matchContentType([]string{"application/x-www-form-urlencoded"}, contentType):
+		values, err := url.ParseQuery(string(body))
+		if err != nil || truncated {
+			return fmt.Sprintf("[%d bytes withheld, the form cannot be redacted]", len(body))
+		}
+		return redactValues(values, b.RedactFields).Encode()
+	case strings.Contains(strings.ToLower(contentType), "json"):
+		redacted, err := b.redactJSON(body)
+		if err != nil || truncated {
+			return fmt.Sprintf("[%d bytes withheld, the document cannot be redacted]", len(body))
+		}
+		return string(redacted)
+	}
+	return string(body) + suffix
+}
+
+func (b *BodyLoggingConfig) redactJSON(body []byte) ([]byte, error) {
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var document interface{}
+	if err := decoder.Decode(&document); err != nil {
+		return nil, err
+	}
+	rules := make([][]string, len(b.RedactFields))
+	for i, field := range b.RedactFields {
+		rules[i] = strings.Split(field, ".")
+	}
+	return json.Marshal(redactJSONValue(document, nil, rules))
+}
+
+// redactJSONValue replaces the members of a document matching a rule, where path holds the member names leading to the value
+func redactJSONValue(value interface{}, path []string, rules [][]string) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, member := range v {
+			memberPath := append(path[:len(path):len(path)], key)
+			if matchRedactionRule(memberPath, rules) {
+				v[key] = redactedValue
+			} else {
+				v[key] = redactJSONValue(member, memberPath, rules)
+			}
+		}
+	case []interface{}:
+		for i, item := range v {
+			v[i] = redactJSONValue(item, path, rules)
+		}
+	}
+	return value
+}
+
+func matchRedactionRule(path []string, rules [][]string) bool {
+	for _, rule := range rules {
+		if len(rule) == 1 {
+			if strings.EqualFold(path[len(path)-1], rule[0]) {
+				return true
+			}
+			continue
+		}
+		if len(rule) != len(path) {
+			continue
+		}
+		matched := true
+		for i := range rule {
+			if !strings.EqualFold(rule[i], path[i]) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			return true
+		}
+	}
+	return false
+}
+
+// redactValues returns a copy of query or form values with the values of the named keys redacted
+func redactValues(values url.Values, names []string) url.Values {
+	redacted := make(url.Values, len(values))
+	for key, value := range values {
+		redacted[key] = value
+		for _, name := range names {
+			if strings.EqualFold(key, name) {
+				redacted[key] = []string{redactedValue}
+				break
+			}
+		}
+	}
+	return redacted
+}
+
+// redactURL returns the URL with the values of the named query parameters redacted
+func redactURL(u *url.URL, names []string) string {
+	if u == nil || len(names) == 0 || u.RawQuery == "" {
+		return fmt.Sprint(u)
+	}
+	redacted := *u
+	redacted.RawQuery = redactValues(u.Query(), names).Encode()
+	return redacted.String()
+}
+
+// capturingResponseWriter copies up to max bytes of a response body
+type capturingResponseWriter struct {
+	http.ResponseWriter
+	body      bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (c *capturingResponseWriter) Write(data []byte) (int, error) {
+	if remaining := c.max - c.body.Len(); remaining < len(data) {
+		c.body.Write(data[:remaining])
+		c.truncated = true
+	} else {
+		c.body.Write(data)
+	}
+	return c.ResponseWriter.Write(data)
+}
+
+// CloseNotify is part of http.CloseNotifier interface, required by restful.Response
+func (c *capturingResponseWriter) CloseNotify() <-chan bool {
+	return c.ResponseWriter.(http.CloseNotifier).CloseNotify()
+}
+
+// Flush flushes the wrapped writer, if it supports flushing
+func (c *capturingResponseWriter) Flush() {
+	if flusher, ok := c.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// failedReader returns the error that interrupted reading a body once the captured prefix is consumed
+type failedReader struct {
+	err error
+}
+
+func (f *failedReader) Read(p []byte) (int, error) {
+	return 0, f.err
+}
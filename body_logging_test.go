@@ -0,0 +1,234 @@
+// Copyright 2020 Daniel Akiva
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goserv
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/emicklei/go-restful"
+	"github.com/stretchr/testify/assert"
+)
+
+func jsonHeader() http.Header {
+	return http.Header{"Content-Type": []string{restful.MIME_JSON}}
+}
+
+func TestRedactJSONBody(t *testing.T) {
+	// given
+	config := &BodyLoggingConfig{RedactFields: []string{"password", "customer.ssn"}}
+	body := `{"password":"secret","customer":{"ssn":"123","name":"ann"},"items":[{"Password":"x","qty":2}],"ssn":"456"}`
+
+	// when
+	logged := config.loggedBody(jsonHeader(), []byte(body), false)
+
+	// then
+	assert.JSONEq(t, `{"password":"[redacted]","customer":{"ssn":"[redacted]","name":"ann"},"items":[{"Password":"[redacted]","qty":2}],"ssn":"456"}`, logged)
+}
+
+func TestRedactFormBody(t *testing.T) {
+	// given
+	config := &BodyLoggingConfig{RedactFields: []string{"password"}}
+	header := http.Header{"Content-Type": []string{"application/x-www-form-urlencoded"}}
+
+	// when
+	logged := config.loggedBody(header, []byte("user=ann&password=secret"), false)
+
+	// then
+	assert.Equal(t, "password=%5Bredacted%5D&user=ann", logged)
+}
+
+func TestTruncatedJSONBodyWithheld(t *testing.T) {
+	// given
+	config := &BodyLoggingConfig{RedactFields: []string{"password"}}
+
+	// when
+	logged := config.loggedBody(jsonHeader(), []byte(`{"password":"sec`), true)
+
+	// then
+	assert.Equal(t, "[16 bytes withheld, the document cannot be redacted]", logged)
+}
+
+func TestBodyContentTypeNotAllowed(t *testing.T) {
+	// given
+	config := &BodyLoggingConfig{}
+	header := http.Header{"Content-Type": []string{"image/png"}}
+
+	// when
+	logged := config.loggedBody(header, []byte{1, 2, 3}, false)
+
+	// then
+	assert.Equal(t, "[3 bytes image/png]", logged)
+}
+
+func TestInvalidBodyLoggingConfig(t *testing.T) {
+	for _, config := range []*BodyLoggingConfig{
+		&BodyLoggingConfig{MaxBodySize: -1},
+		&BodyLoggingConfig{ContentTypes: []string{"json"}},
+		&BodyLoggingConfig{RedactFields: []string{"customer..ssn"}},
+	} {
+		// when
+		err := config.Validate()
+
+		// then
+		assert.Error(t, err)
+	}
+}
+
+func newEchoContainer(filter *RestfulLoggingFilter, routeConfig *BodyLoggingConfig) *restful.Container {
+	ws := new(restful.WebService)
+	ws.Path("/accounts").Consumes(restful.MIME_JSON, "text/plain").Produces(restful.MIME_JSON)
+	builder := ws.POST("").To(func(request *restful.Request, response *restful.Response) {
+		body, _ := ioutil.ReadAll(request.Request.Body)
+		response.AddHeader("Content-Type", restful.MIME_JSON)
+		response.AddHeader("Set-Cookie", "session=abc")
+		response.WriteHeader(http.StatusCreated)
+		response.Write(body)
+	})
+	if routeConfig != nil {
+		LogBodies(builder, routeConfig)
+	}
+	ws.Route(builder)
+	container := restful.NewContainer()
+	container.Filter(filter.Filter)
+	container.Add(ws)
+	filter.SetContainer(container)
+	return container
+}
+
+func TestRestfulLoggingFilterBodies(t *testing.T) {
+	// given
+	logger, buffer := newBufferedLogger("body_logging_test", NewJSONFormatter())
+	filter := NewRestfulLoggingFilter(logger)
+	filter.SetBodyLogging(&BodyLoggingConfig{LogRequestBody: true, LogResponseBody: true, RedactQueryParams: []string{"token"}, RedactFields: []string{"password"}})
+	container := newEchoContainer(filter, nil)
+	body := `{"user":"ann","password":"secret"}`
+	req := httptest.NewRequest(http.MethodPost, "/accounts?token=abc&page=1", strings.NewReader(body))
+	req.Header.Set("Content-Type", restful.MIME_JSON)
+	req.Header.Set("X-Api-Key", "key")
+	recorder := httptest.NewRecorder()
+
+	// when
+	container.ServeHTTP(recorder, req)
+
+	// then
+	assert.Equal(t, body, recorder.Body.String())
+	entries := decodeLogLines(t, buffer)
+	assert.Len(t, entries, 4)
+	assert.Contains(t, entries[0]["message"], "/accounts?page=1&token=%5Bredacted%5D")
+	assert.Contains(t, entries[0]["message"], "X-Api-Key:[hidden]")
+	assert.NotContains(t, entries[0]["message"], "abc")
+	assert.Contains(t, entries[1]["message"], "Set-Cookie:[hidden]")
+	assert.JSONEq(t, `{"user":"ann","password":"[redacted]"}`, entries[2]["request_body"].(string))
+	assert.JSONEq(t, `{"user":"ann","password":"[redacted]"}`, entries[3]["response_body"].(string))
+}
+
+func TestLogBodiesRoute(t *testing.T) {
+	// given
+	logger, buffer := newBufferedLogger("body_logging_route_test", NewJSONFormatter())
+	filter := NewRestfulLoggingFilter(logger)
+	container := newEchoContainer(filter, &BodyLoggingConfig{LogRequestBody: true, MaxBodySize: 5})
+	req := httptest.NewRequest(http.MethodPost, "/accounts", strings.NewReader(`plain text body`))
+	req.Header.Set("Content-Type", "text/plain")
+	recorder := httptest.NewRecorder()
+
+	// when
+	container.ServeHTTP(recorder, req)
+
+	// then
+	assert.Equal(t, "plain text body", recorder.Body.String())
+	entries := decodeLogLines(t, buffer)
+	assert.Len(t, entries, 3)
+	assert.Equal(t, "plain...[truncated]", entries[2]["request_body"])
+}
+
+func TestCapturingResponseWriterCloseNotify(t *testing.T) {
+	// given
+	logger, _ := newBufferedLogger("body_logging_close_notify_test", NewJSONFormatter())
+	filter := NewRestfulLoggingFilter(logger)
+	filter.SetBodyLogging(&BodyLoggingConfig{LogResponseBody: true})
+	ws := new(restful.WebService)
+	ws.Route(ws.GET("/events").To(func(request *restful.Request, response *restful.Response) {
+		response.CloseNotify()
+		response.WriteHeader(http.StatusNoContent)
+	}))
+	container := restful.NewContainer()
+	container.Filter(filter.Filter)
+	container.Add(ws)
+	// the response writer of the server implements http.CloseNotifier, unlike a recorder
+	server := httptest.NewServer(container)
+	defer server.Close()
+
+	// when
+	resp, err := http.Get(server.URL + "/events")
+
+	// then
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, http.StatusNoContent, resp.StatusCode)
+}
+
+func TestLogBodiesRouteRedactsRequestLine(t *testing.T) {
+	// given
+	logger, buffer := newBufferedLogger("body_logging_request_line_test", NewJSONFormatter())
+	filter := NewRestfulLoggingFilter(logger)
+	container := newEchoContainer(filter, &BodyLoggingConfig{RedactHeaders: []string{"X-Session"}, RedactQueryParams: []string{"token"}})
+	req := httptest.NewRequest(http.MethodPost, "/accounts?token=abc", strings.NewReader(`{}`))
+	req.Header.Set("Content-Type", restful.MIME_JSON)
+	req.Header.Set("X-Session", "secret")
+	recorder := httptest.NewRecorder()
+
+	// when
+	container.ServeHTTP(recorder, req)
+
+	// then
+	entries := decodeLogLines(t, buffer)
+	assert.Len(t, entries, 2)
+	assert.Contains(t, entries[0]["message"], "[Request POST /accounts?token=%5Bredacted%5D]")
+	assert.Contains(t, entries[0]["message"], "X-Session:[hidden]")
+	assert.NotContains(t, entries[0]["message"], "secret")
+	assert.NotContains(t, entries[0]["message"], "abc")
+}
+
+func TestRequestLineLoggedBeforeRoute(t *testing.T) {
+	// given
+	logger, buffer := newBufferedLogger("body_logging_order_test", NewJSONFormatter())
+	filter := NewRestfulLoggingFilter(logger)
+	ws := new(restful.WebService)
+	ws.Route(LogBodies(ws.GET("/accounts").To(func(request *restful.Request, response *restful.Response) {
+		logger.Info("loading accounts")
+		panic("database unavailable")
+	}), &BodyLoggingConfig{RedactQueryParams: []string{"token"}}))
+	container := restful.NewContainer()
+	container.DoNotRecover(true)
+	container.Filter(filter.Filter)
+	container.Add(ws)
+	filter.SetContainer(container)
+
+	// when
+	func() {
+		defer func() { recover() }()
+		container.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/accounts?token=abc", nil))
+	}()
+
+	// then
+	entries := decodeLogLines(t, buffer)
+	assert.Len(t, entries, 2)
+	assert.Contains(t, entries[0]["message"], "[Request GET /accounts?token=%5Bredacted%5D]")
+	assert.Equal(t, "loading accounts", entries[1]["message"])
+}
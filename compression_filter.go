@@ -0,0 +1,320 @@
+// Copyright 2020 Daniel Akiva
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goserv
+
+import (
+	"bufio"
+	"compress/flate"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+	"github.com/emicklei/go-restful"
+)
+
+const (
+	// DefaultCompressionMinSize is the default minimum response size, in bytes, before a response is compressed
+	DefaultCompressionMinSize = 1024
+)
+
+// DefaultCompressionContentTypes is the default allowlist of compressible content types
+var DefaultCompressionContentTypes = []string{
+	"text/*",
+	"application/json",
+	"application/problem+json",
+	"application/javascript",
+	"application/xml",
+	"image/svg+xml",
+}
+
+// CompressionConfig represents configuration for the CompressionFilter. Excluded prefixes opt entire path trees out of compression.
+type CompressionConfig struct {
+	MinSize          int      `json:"min_size"`
+	ContentTypes     []string `json:"content_types"`
+	ExcludedPrefixes []string `json:"excluded_prefixes"`
+}
+
+// Validate ensures the configuration is valid
+func (c *CompressionConfig) Validate() error {
+	if c.MinSize < 0 {
+		return errors.New("compression min size must not be negative")
+	}
+	for _, contentType := range c.ContentTypes {
+		if !strings.Contains(contentType, "/") {
+			return errors.New("invalid compression content type " + contentType)
+		}
+	}
+	return nil
+}
+
+// compressEncoder is satisfied by the gzip, flate and brotli writers
+type compressEncoder interface {
+	io.WriteCloser
+	Flush() error
+}
+
+// CompressionFilter represents a go-restful filter that compresses responses using brotli, gzip or deflate, negotiated by the Accept-Encoding request header.
+// Responses are buffered until MinSize bytes are written so that small responses are sent as is. A handler flushing the response commits to compression early, supporting streamed responses.
+// Routes matching the Exclusions white list, or paths starting with an excluded prefix, are never compressed.
+type CompressionFilter struct {
+	Exclusions       *URLWhiteList
+	minSize          int
+	contentTypes     []string
+	excludedPrefixes []string
+}
+
+// NewCompressionFilter initializes a new filter instance. A nil configuration uses the defaults.
+func NewCompressionFilter(config *CompressionConfig) *CompressionFilter {
+	c := &CompressionFilter{
+		Exclusions:   NewURLWhiteList(),
+		minSize:      DefaultCompressionMinSize,
+		contentTypes: DefaultCompressionContentTypes,
+	}
+	if config != nil {
+		if config.MinSize > 0 {
+			c.minSize = config.MinSize
+		}
+		if len(config.ContentTypes) > 0 {
+			c.contentTypes = config.ContentTypes
+		}
+		c.excludedPrefixes = config.ExcludedPrefixes
+	}
+	return c
+}
+
+// Filter fits in the go-restful filterchain, replacing the response writer with a compressing writer.
+func (c *CompressionFilter) Filter(request *restful.Request, response *restful.Response, chain *restful.FilterChain) {
+	if request.Request.Method == http.MethodHead || c.excluded(request.Request) {
+		chain.ProcessFilter(request, response)
+		return
+	}
+	writer := &compressingWriter{
+		ResponseWriter: response.ResponseWriter,
+		filter:         c,
+		encoding:       negotiateEncoding(request.Request.Header.Get("Accept-Encoding")),
+	}
+	response.ResponseWriter = writer
+	defer func() {
+		writer.Close()
+		response.ResponseWriter = writer.ResponseWriter
+	}()
+	chain.ProcessFilter(request, response)
+}
+
+// ExcludeSwagger opts the swagger api docs route and the static swagger files out of compression
+func (c *CompressionFilter) ExcludeSwagger(config *SwaggerConfig) {
+	c.Exclusions.AddURL(config.APIPath)
+	c.excludedPrefixes = append(c.excludedPrefixes, config.SwaggerPath)
+}
+
+func (c *CompressionFilter) excluded(request *http.Request) bool {
+	for _, prefix := range c.excludedPrefixes {
+		if strings.HasPrefix(request.URL.Path, prefix) {
+			return true
+		}
+	}
+	return c.Exclusions != nil && c.Exclusions.Match(request.URL.Path, request.Method)
+}
+
+func (c *CompressionFilter) compressible(contentType string) bool {
+	return matchContentType(c.contentTypes, contentType)
+}
+
+// matchContentType returns true if a content type, ignoring parameters and case, matches an allowlist entry such as application/json or text/*
+func matchContentType(allowlist []string, contentType string) bool {
+	if idx := strings.Index(contentType, ";"); idx >= 0 {
+		contentType = contentType[:idx]
+	}
+	contentType = strings.ToLower(strings.TrimSpace(contentType))
+	for _, allowed := range allowlist {
+		allowed = strings.ToLower(allowed)
+		if allowed == contentType || (strings.HasSuffix(allowed, "/*") && strings.HasPrefix(contentType, allowed[:len(allowed)-1])) {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiateEncoding returns the preferred supported encoding acceptable to the client, or an empty string if the response should not be encoded.
+// The client's quality values take precedence, ties are broken by server preference (br, gzip, deflate).
+func negotiateEncoding(acceptEncoding string) string {
+	if acceptEncoding == "" {
+		return ""
+	}
+	qualities := map[string]float64{}
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		fields := strings.Split(part, ";")
+		name := strings.ToLower(strings.TrimSpace(fields[0]))
+		q := 1.0
+		for _, param := range fields[1:] {
+			param = strings.TrimSpace(param)
+			if strings.HasPrefix(param, "q=") {
+				if v, err := strconv.ParseFloat(param[2:], 64); err == nil {
+					q = v
+				}
+			}
+		}
+		qualities[name] = q
+	}
+	best, bestQ := "", 0.0
+	for _, encoding := range []string{"br", "gzip", "deflate"} {
+		q, ok := qualities[encoding]
+		if !ok {
+			q, ok = qualities["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+func newEncoder(encoding string, w io.Writer) compressEncoder {
+	switch encoding {
+	case "br":
+		return brotli.NewWriterLevel(w, brotli.DefaultCompression)
+	case "gzip":
+		return gzip.NewWriter(w)
+	default:
+		encoder, _ := flate.NewWriter(w, flate.DefaultCompression)
+		return encoder
+	}
+}
+
+// compressingWriter buffers the response until the compression decision can be made, then either encodes or passes the response through.
+type compressingWriter struct {
+	http.ResponseWriter
+	filter     *CompressionFilter
+	encoding   string
+	status     int
+	buffer     []byte
+	decided    bool
+	encoder    compressEncoder
+	headerSent bool
+}
+
+func (w *compressingWriter) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+	if status < http.StatusOK || status == http.StatusNoContent || status == http.StatusNotModified {
+		// bodiless responses are never compressed
+		w.decide(false)
+	}
+}
+
+func (w *compressingWriter) Write(b []byte) (int, error) {
+	if w.decided {
+		return w.writeDecided(b)
+	}
+	w.buffer = append(w.buffer, b...)
+	if len(w.buffer) >= w.filter.minSize {
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+	}
+	return len(b), nil
+}
+
+// Flush commits to a compression decision and flushes encoded data through to the client
+func (w *compressingWriter) Flush() {
+	if !w.decided {
+		w.decide(true)
+	}
+	if w.encoder != nil {
+		w.encoder.Flush()
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Close completes the response, deciding on small responses and closing the encoder
+func (w *compressingWriter) Close() error {
+	if !w.decided {
+		if err := w.decide(len(w.buffer) >= w.filter.minSize); err != nil {
+			return err
+		}
+	}
+	if w.encoder != nil {
+		return w.encoder.Close()
+	}
+	return nil
+}
+
+// CloseNotify is part of http.CloseNotifier interface, required by restful.Response
+func (w *compressingWriter) CloseNotify() <-chan bool {
+	return w.ResponseWriter.(http.CloseNotifier).CloseNotify()
+}
+
+// Hijack implements http.Hijacker if the underlying writer supports it
+func (w *compressingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hijacker, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return hijacker.Hijack()
+	}
+	return nil, nil, errors.New("http.Hijacker not implemented by underlying http.ResponseWriter")
+}
+
+func (w *compressingWriter) decide(largeEnough bool) error {
+	if w.decided {
+		return nil
+	}
+	w.decided = true
+	header := w.Header()
+	contentType := header.Get("Content-Type")
+	if contentType == "" && len(w.buffer) > 0 {
+		contentType = http.DetectContentType(w.buffer)
+	}
+	eligible := header.Get("Content-Encoding") == "" && w.filter.compressible(contentType)
+	if eligible {
+		// the response representation depends on the request's Accept-Encoding
+		header.Add("Vary", "Accept-Encoding")
+	}
+	if eligible && largeEnough && w.encoding != "" {
+		header.Set("Content-Encoding", w.encoding)
+		header.Del("Content-Length")
+		w.encoder = newEncoder(w.encoding, w.ResponseWriter)
+	}
+	w.sendHeader()
+	buffered := w.buffer
+	w.buffer = nil
+	if len(buffered) > 0 {
+		if _, err := w.writeDecided(buffered); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (w *compressingWriter) sendHeader() {
+	if !w.headerSent {
+		w.headerSent = true
+		if w.status != 0 {
+			w.ResponseWriter.WriteHeader(w.status)
+		}
+	}
+}
+
+func (w *compressingWriter) writeDecided(b []byte) (int, error) {
+	if w.encoder != nil {
+		return w.encoder.Write(b)
+	}
+	return w.ResponseWriter.Write(b)
+}
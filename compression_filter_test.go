@@ -0,0 +1,183 @@
+// Copyright 2020 Daniel Akiva
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goserv
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/emicklei/go-restful"
+	"github.com/stretchr/testify/assert"
+)
+
+func newCompressionContainer(filter *CompressionFilter, body string) *restful.Container {
+	return newCompressionHandlerContainer(filter, func(request *restful.Request, response *restful.Response) {
+		response.AddHeader("Content-Type", "application/json")
+		response.WriteHeader(http.StatusOK)
+		response.Write([]byte(body))
+	})
+}
+
+func newCompressionHandlerContainer(filter *CompressionFilter, handler restful.RouteFunction) *restful.Container {
+	container := restful.NewContainer()
+	container.Filter(filter.Filter)
+	ws := new(restful.WebService)
+	ws.Route(ws.GET("/foo").To(handler))
+	container.Add(ws)
+	return container
+}
+
+func TestGzipCompression(t *testing.T) {
+	// given
+	body := strings.Repeat("a", 2048)
+	container := newCompressionContainer(NewCompressionFilter(nil), body)
+	req := httptest.NewRequest(http.MethodGet, "/foo", nil)
+	req.Header.Set("Accept-Encoding", "gzip, deflate")
+	recorder := httptest.NewRecorder()
+
+	// when
+	container.ServeHTTP(recorder, req)
+
+	// then
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, "gzip", recorder.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", recorder.Header().Get("Vary"))
+	reader, err := gzip.NewReader(recorder.Body)
+	assert.NoError(t, err)
+	decoded, err := ioutil.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.Equal(t, body, string(decoded))
+}
+
+func TestBrotliPreferred(t *testing.T) {
+	// given
+	body := strings.Repeat("a", 2048)
+	container := newCompressionContainer(NewCompressionFilter(nil), body)
+	req := httptest.NewRequest(http.MethodGet, "/foo", nil)
+	req.Header.Set("Accept-Encoding", "gzip, br")
+	recorder := httptest.NewRecorder()
+
+	// when
+	container.ServeHTTP(recorder, req)
+
+	// then
+	assert.Equal(t, "br", recorder.Header().Get("Content-Encoding"))
+	decoded, err := ioutil.ReadAll(brotli.NewReader(recorder.Body))
+	assert.NoError(t, err)
+	assert.Equal(t, body, string(decoded))
+}
+
+func TestCompressionContentTypeIgnoresCase(t *testing.T) {
+	// given
+	body := strings.Repeat("a", 2048)
+	filter := NewCompressionFilter(&CompressionConfig{ContentTypes: []string{"Application/JSON"}})
+	container := newCompressionHandlerContainer(filter, func(request *restful.Request, response *restful.Response) {
+		response.AddHeader("Content-Type", "APPLICATION/json; charset=UTF-8")
+		response.Write([]byte(body))
+	})
+	req := httptest.NewRequest(http.MethodGet, "/foo", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	recorder := httptest.NewRecorder()
+
+	// when
+	container.ServeHTTP(recorder, req)
+
+	// then
+	assert.Equal(t, "gzip", recorder.Header().Get("Content-Encoding"))
+}
+
+func TestCompressionFlushStreamedResponse(t *testing.T) {
+	// given
+	recorder := httptest.NewRecorder()
+	var flushed string
+	container := newCompressionHandlerContainer(NewCompressionFilter(nil), func(request *restful.Request, response *restful.Response) {
+		response.AddHeader("Content-Type", "text/plain")
+		response.Write([]byte("first"))
+		response.Flush()
+		// the flushed chunk must be decodable before the response completes
+		reader, err := gzip.NewReader(bytes.NewReader(recorder.Body.Bytes()))
+		if assert.NoError(t, err) {
+			chunk := make([]byte, len("first"))
+			_, err = io.ReadFull(reader, chunk)
+			assert.NoError(t, err)
+			flushed = string(chunk)
+		}
+		response.Write([]byte("second"))
+	})
+	req := httptest.NewRequest(http.MethodGet, "/foo", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+
+	// when
+	container.ServeHTTP(recorder, req)
+
+	// then
+	assert.True(t, recorder.Flushed)
+	assert.Equal(t, "first", flushed)
+	assert.Equal(t, "gzip", recorder.Header().Get("Content-Encoding"))
+	reader, err := gzip.NewReader(recorder.Body)
+	assert.NoError(t, err)
+	decoded, err := ioutil.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.Equal(t, "firstsecond", string(decoded))
+}
+
+func TestCompressionBelowMinSize(t *testing.T) {
+	// given
+	container := newCompressionContainer(NewCompressionFilter(nil), `{"a":1}`)
+	req := httptest.NewRequest(http.MethodGet, "/foo", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	recorder := httptest.NewRecorder()
+
+	// when
+	container.ServeHTTP(recorder, req)
+
+	// then
+	assert.Empty(t, recorder.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", recorder.Header().Get("Vary"))
+	assert.Equal(t, `{"a":1}`, recorder.Body.String())
+}
+
+func TestCompressionExcludedRoute(t *testing.T) {
+	// given
+	body := strings.Repeat("a", 2048)
+	filter := NewCompressionFilter(nil)
+	filter.Exclusions.AddURL("/foo", "GET")
+	container := newCompressionContainer(filter, body)
+	req := httptest.NewRequest(http.MethodGet, "/foo", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	recorder := httptest.NewRecorder()
+
+	// when
+	container.ServeHTTP(recorder, req)
+
+	// then
+	assert.Empty(t, recorder.Header().Get("Content-Encoding"))
+	assert.Equal(t, body, recorder.Body.String())
+}
+
+func TestNegotiateEncoding(t *testing.T) {
+	assert.Equal(t, "", negotiateEncoding(""))
+	assert.Equal(t, "gzip", negotiateEncoding("gzip;q=1.0, br;q=0.5"))
+	assert.Equal(t, "br", negotiateEncoding("*"))
+	assert.Equal(t, "gzip", negotiateEncoding("br;q=0, gzip"))
+	assert.Equal(t, "", negotiateEncoding("identity"))
+}
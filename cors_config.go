@@ -0,0 +1,50 @@
+// Copyright 2020 Daniel Akiva
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goserv
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// CORSConfig represents configuration for cross-origin resource sharing. Allowed origins are matched exactly, or by wildcard subdomain (ie "https://*.example.com"). A single "*" origin allows any origin.
+type CORSConfig struct {
+	AllowedOrigins   []string `json:"allowed_origins"`
+	AllowedMethods   []string `json:"allowed_methods"`
+	AllowedHeaders   []string `json:"allowed_headers"`
+	ExposedHeaders   []string `json:"exposed_headers"`
+	AllowCredentials bool     `json:"allow_credentials"`
+	MaxAge           int      `json:"max_age"`
+}
+
+// Validate ensures the configuration is valid
+func (c *CORSConfig) Validate() error {
+	if len(c.AllowedOrigins) == 0 {
+		return errors.New("at least one allowed origin must be specified")
+	}
+	for _, origin := range c.AllowedOrigins {
+		if origin == "*" {
+			continue
+		}
+		if idx := strings.Index(origin, "*"); idx >= 0 && (strings.Count(origin, "*") > 1 || !strings.HasSuffix(origin[:idx], "://") || !strings.HasPrefix(origin[idx+1:], ".")) {
+			return fmt.Errorf("invalid allowed origin %s, wildcards are only supported as a leading subdomain", origin)
+		}
+	}
+	if c.MaxAge < 0 {
+		return errors.New("max age must not be negative")
+	}
+	return nil
+}
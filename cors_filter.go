@@ -0,0 +1,160 @@
+// Copyright 2020 Daniel Akiva
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goserv
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/emicklei/go-restful"
+)
+
+var defaultCORSMethods = []string{"GET", "HEAD", "POST", "PUT", "PATCH", "DELETE"}
+
+// CORSFilter represents a go-restful filter that handles cross-origin resource sharing. Preflight requests are answered directly by the filter and never reach the rest of the chain,
+// so the filter should be installed as a container filter ahead of the TokenAuthFilter.
+type CORSFilter struct {
+	allowAll       bool
+	origins        []string
+	methods        []string
+	headers        []string
+	allowedHeaders string
+	exposedHeaders string
+	credentials    bool
+	maxAge         string
+}
+
+// NewCORSFilter initializes a new filter instance from configuration. If no methods are configured, the common REST methods are allowed. If no headers are configured, any requested header is allowed.
+func NewCORSFilter(config *CORSConfig) *CORSFilter {
+	c := &CORSFilter{
+		credentials:    config.AllowCredentials,
+		allowedHeaders: strings.Join(config.AllowedHeaders, ", "),
+		exposedHeaders: strings.Join(config.ExposedHeaders, ", "),
+	}
+	for _, origin := range config.AllowedOrigins {
+		if origin == "*" {
+			c.allowAll = true
+		}
+		c.origins = append(c.origins, strings.ToLower(origin))
+	}
+	methods := config.AllowedMethods
+	if len(methods) == 0 {
+		methods = defaultCORSMethods
+	}
+	for _, m := range methods {
+		c.methods = append(c.methods, strings.ToUpper(m))
+	}
+	for _, h := range config.AllowedHeaders {
+		c.headers = append(c.headers, strings.ToLower(h))
+	}
+	if config.MaxAge > 0 {
+		c.maxAge = strconv.Itoa(config.MaxAge)
+	}
+	return c
+}
+
+// Filter fits in the go-restful filterchain, answering preflight requests and decorating cross-origin responses.
+func (c *CORSFilter) Filter(request *restful.Request, response *restful.Response, chain *restful.FilterChain) {
+	origin := request.Request.Header.Get("Origin")
+	if origin == "" {
+		chain.ProcessFilter(request, response)
+		return
+	}
+	response.AddHeader("Vary", "Origin")
+	preflight := request.Request.Method == http.MethodOptions && request.Request.Header.Get("Access-Control-Request-Method") != ""
+	if !c.originAllowed(origin) {
+		if preflight {
+			WriteRequestError(request, response, &AccessDeniedError{Err: fmt.Errorf("origin %s is not allowed", origin)})
+			return
+		}
+		chain.ProcessFilter(request, response)
+		return
+	}
+	if preflight {
+		c.handlePreflight(origin, request, response)
+		return
+	}
+	c.setOriginHeaders(origin, response)
+	if c.exposedHeaders != "" {
+		response.AddHeader("Access-Control-Expose-Headers", c.exposedHeaders)
+	}
+	chain.ProcessFilter(request, response)
+}
+
+func (c *CORSFilter) handlePreflight(origin string, request *restful.Request, response *restful.Response) {
+	method := strings.ToUpper(request.Request.Header.Get("Access-Control-Request-Method"))
+	if !methodMatch(c.methods, method) {
+		WriteRequestError(request, response, &AccessDeniedError{Err: fmt.Errorf("method %s is not allowed", method)})
+		return
+	}
+	requestedHeaders := request.Request.Header.Get("Access-Control-Request-Headers")
+	allowedHeaders := c.allowedHeaders
+	if len(c.headers) == 0 {
+		// no headers configured, reflect the requested headers
+		allowedHeaders = requestedHeaders
+	} else {
+		for _, h := range strings.Split(requestedHeaders, ",") {
+			h = strings.ToLower(strings.TrimSpace(h))
+			if h != "" && !methodMatch(c.headers, h) {
+				WriteRequestError(request, response, &AccessDeniedError{Err: fmt.Errorf("header %s is not allowed", h)})
+				return
+			}
+		}
+	}
+	response.AddHeader("Vary", "Access-Control-Request-Method")
+	response.AddHeader("Vary", "Access-Control-Request-Headers")
+	c.setOriginHeaders(origin, response)
+	response.AddHeader("Access-Control-Allow-Methods", strings.Join(c.methods, ", "))
+	if allowedHeaders != "" {
+		response.AddHeader("Access-Control-Allow-Headers", allowedHeaders)
+	}
+	if c.maxAge != "" {
+		response.AddHeader("Access-Control-Max-Age", c.maxAge)
+	}
+	response.WriteHeader(http.StatusNoContent)
+}
+
+func (c *CORSFilter) setOriginHeaders(origin string, response *restful.Response) {
+	if c.allowAll && !c.credentials {
+		response.AddHeader("Access-Control-Allow-Origin", "*")
+	} else {
+		// credentialed requests may not use the wildcard, echo the origin instead
+		response.AddHeader("Access-Control-Allow-Origin", origin)
+	}
+	if c.credentials {
+		response.AddHeader("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+func (c *CORSFilter) originAllowed(origin string) bool {
+	if c.allowAll {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	for _, allowed := range c.origins {
+		if allowed == origin {
+			return true
+		}
+		if idx := strings.Index(allowed, "*"); idx >= 0 {
+			scheme, suffix := allowed[:idx], allowed[idx+1:]
+			if strings.HasPrefix(origin, scheme) && strings.HasSuffix(origin, suffix) && len(origin) > len(scheme)+len(suffix) {
+				return true
+			}
+		}
+	}
+	return false
+}
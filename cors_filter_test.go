@@ -0,0 +1,132 @@
+// Copyright 2020 Daniel Akiva
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goserv
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/emicklei/go-restful"
+	"github.com/stretchr/testify/assert"
+)
+
+func newCORSContainer(config *CORSConfig, targetCalled *bool) *restful.Container {
+	container := restful.NewContainer()
+	container.Filter(NewCORSFilter(config).Filter)
+	container.Filter((&TokenAuthFilter{}).Filter)
+	ws := new(restful.WebService)
+	ws.Route(ws.GET("/foo").To(func(*restful.Request, *restful.Response) {
+		*targetCalled = true
+	}))
+	container.Add(ws)
+	return container
+}
+
+func TestCORSPreflightBeforeAuth(t *testing.T) {
+	// given
+	targetCalled := false
+	container := newCORSContainer(&CORSConfig{
+		AllowedOrigins: []string{"https://*.example.com"},
+		AllowedHeaders: []string{"Authorization"},
+		MaxAge:         600,
+	}, &targetCalled)
+	req := httptest.NewRequest(http.MethodOptions, "/foo", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	req.Header.Set("Access-Control-Request-Headers", "authorization")
+	recorder := httptest.NewRecorder()
+
+	// when
+	container.ServeHTTP(recorder, req)
+
+	// then
+	assert.Equal(t, http.StatusNoContent, recorder.Code)
+	assert.Equal(t, "https://app.example.com", recorder.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "Authorization", recorder.Header().Get("Access-Control-Allow-Headers"))
+	assert.Equal(t, "600", recorder.Header().Get("Access-Control-Max-Age"))
+	assert.False(t, targetCalled)
+}
+
+func TestCORSPreflightDisallowedOrigin(t *testing.T) {
+	// given
+	targetCalled := false
+	container := newCORSContainer(&CORSConfig{AllowedOrigins: []string{"https://*.example.com"}}, &targetCalled)
+	req := httptest.NewRequest(http.MethodOptions, "/foo", nil)
+	req.Header.Set("Origin", "https://example.com.evil.org")
+	req.Header.Set("Access-Control-Request-Method", "GET")
+	recorder := httptest.NewRecorder()
+
+	// when
+	container.ServeHTTP(recorder, req)
+
+	// then
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+	assert.Empty(t, recorder.Header().Get("Access-Control-Allow-Origin"))
+}
+
+func TestCORSPreflightDisallowedMethod(t *testing.T) {
+	// given
+	targetCalled := false
+	container := newCORSContainer(&CORSConfig{AllowedOrigins: []string{"*"}, AllowedMethods: []string{"GET"}}, &targetCalled)
+	req := httptest.NewRequest(http.MethodOptions, "/foo", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "DELETE")
+	recorder := httptest.NewRecorder()
+
+	// when
+	container.ServeHTTP(recorder, req)
+
+	// then
+	assert.Equal(t, http.StatusForbidden, recorder.Code)
+}
+
+func TestCORSActualRequestWithCredentials(t *testing.T) {
+	// given
+	filter := NewCORSFilter(&CORSConfig{
+		AllowedOrigins:   []string{"*"},
+		ExposedHeaders:   []string{"Location"},
+		AllowCredentials: true,
+	})
+	req := httptest.NewRequest(http.MethodGet, "/foo", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	recorder := httptest.NewRecorder()
+	targetCalled := false
+	chain := &restful.FilterChain{
+		Target: func(*restful.Request, *restful.Response) {
+			targetCalled = true
+		},
+	}
+
+	// when
+	filter.Filter(restful.NewRequest(req), restful.NewResponse(recorder), chain)
+
+	// then
+	assert.True(t, targetCalled)
+	assert.Equal(t, "https://app.example.com", recorder.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", recorder.Header().Get("Access-Control-Allow-Credentials"))
+	assert.Equal(t, "Location", recorder.Header().Get("Access-Control-Expose-Headers"))
+}
+
+func TestInvalidCORSConfigWildcard(t *testing.T) {
+	// given
+	config := &CORSConfig{AllowedOrigins: []string{"https://app.*.com"}}
+
+	// when
+	err := config.Validate()
+
+	// then
+	assert.Error(t, err)
+}
@@ -15,6 +15,7 @@
 package goserv
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"strings"
@@ -30,16 +31,42 @@ const (
 	pqUniqueViolationCode = "23505"
 )
 
+// TracingDBContextProvider is a DBContextProvider whose database contexts log statements along with the trace context of a request. Handlers obtain a provider for the request being handled from the context of the request, ie
+//
+//	provider.WithContext(request.Request.Context()).GetContext()
+//
+// The RestfulLoggingFilter stores the trace context in the context of each request.
+type TracingDBContextProvider interface {
+	dbx.DBContextProvider
+	// WithContext returns a provider logging statements along with the trace context carried by a context
+	WithContext(ctx context.Context) dbx.DBContextProvider
+}
+
 // NewDBContextProviderSQLXWrapper initializes and returns a wrapped DBContextProvider instance
-func NewDBContextProviderSQLXWrapper(db *sqlx.DB, logDB bool, logger *logging.Logger) dbx.DBContextProvider {
+func NewDBContextProviderSQLXWrapper(db *sqlx.DB, logDB bool, logger *logging.Logger) TracingDBContextProvider {
 	return &DBContextProviderSQLXWrapper{db: db, logDB: logDB, logger: logger}
 }
 
+// DBContextProviderWithContext returns a provider logging statements along with the trace context carried by a context if the provider is a TracingDBContextProvider, otherwise the provider itself
+func DBContextProviderWithContext(provider dbx.DBContextProvider, ctx context.Context) dbx.DBContextProvider {
+	if tracing, ok := provider.(TracingDBContextProvider); ok {
+		return tracing.WithContext(ctx)
+	}
+	return provider
+}
+
 // DBContextProviderSQLXWrapper wraps a sqlx DB as a content provider
 type DBContextProviderSQLXWrapper struct {
 	db     *sqlx.DB
 	logDB  bool
 	logger *logging.Logger
+	trace  *TraceContext
+}
+
+// WithContext returns a provider whose database contexts log statements along with the trace context carried by a context, ie the context of the request being handled
+func (d *DBContextProviderSQLXWrapper) WithContext(ctx context.Context) dbx.DBContextProvider {
+	trace, _ := TraceFromContext(ctx)
+	return &DBContextProviderSQLXWrapper{db: d.db, logDB: d.logDB, logger: d.logger, trace: trace}
 }
 
 // GetTxContext returns a transaction context, or an error
@@ -48,58 +75,60 @@ func (d *DBContextProviderSQLXWrapper) GetTxContext() (dbx.DBTxContext, error) {
 	if err != nil {
 		return nil, err
 	}
-	return &loggableDBTxContext{tx: tx, logDB: d.logDB, logger: d.logger}, nil
+	return &loggableDBTxContext{tx: tx, logDB: d.logDB, logger: d.logger, trace: d.trace}, nil
 }
 
 // GetContext returns a database context
 func (d *DBContextProviderSQLXWrapper) GetContext() (dbx.DBContext, error) {
-	return &loggableDBContext{ctx: d.db, logDB: d.logDB, logger: d.logger}, nil
+	return &loggableDBContext{ctx: d.db, logDB: d.logDB, logger: d.logger, trace: d.trace}, nil
 }
 
 type loggableDBContext struct {
 	ctx    dbx.DBContext
 	logDB  bool
 	logger *logging.Logger
+	trace  *TraceContext
 }
 
 func (l *loggableDBContext) NamedExec(query string, arg interface{}) (sql.Result, error) {
 	start := time.Now()
 	res, err := l.ctx.NamedExec(query, arg)
 	if l.logDB {
-		l.logger.Debugf("[named exec time=%s]:%s [arg]:%v", time.Now().Sub(start), query, arg)
+		logDBStatement(l.logger, l.trace, "named exec", start, "[named exec time=%s]:%s [arg]:%v", query, arg)
 	}
-	return res, interpretDBError(err, l.logDB, l.logger)
+	return res, interpretDBError(err, l.logDB, l.logger, l.trace)
 }
 
 func (l *loggableDBContext) NamedQuery(query string, arg interface{}) (*sqlx.Rows, error) {
 	start := time.Now()
 	res, err := l.ctx.NamedQuery(query, arg)
 	if l.logDB {
-		l.logger.Debugf("[named query time=%s]:%s [arg]:%v", time.Now().Sub(start), query, arg)
+		logDBStatement(l.logger, l.trace, "named query", start, "[named query time=%s]:%s [arg]:%v", query, arg)
 	}
-	return res, interpretDBError(err, l.logDB, l.logger)
+	return res, interpretDBError(err, l.logDB, l.logger, l.trace)
 }
 
 func (l *loggableDBContext) PrepareNamed(query string) (*sqlx.NamedStmt, error) {
 	start := time.Now()
 	res, err := l.ctx.PrepareNamed(query)
 	if l.logDB {
-		l.logger.Debugf("[preparing time=%s]:%s", time.Now().Sub(start), query)
+		logDBStatement(l.logger, l.trace, "preparing", start, "[preparing time=%s]:%s", query)
 	}
-	return res, interpretDBError(err, l.logDB, l.logger)
+	return res, interpretDBError(err, l.logDB, l.logger, l.trace)
 }
 
 type loggableDBTxContext struct {
 	tx     dbx.DBTxContext
 	logDB  bool
 	logger *logging.Logger
+	trace  *TraceContext
 }
 
 func (l *loggableDBTxContext) Commit() error {
 	start := time.Now()
 	err := l.tx.Commit()
 	if l.logDB {
-		l.logger.Debugf("[tx commit time=%s]", time.Now().Sub(start))
+		logDBStatement(l.logger, l.trace, "tx commit", start, "[tx commit time=%s]")
 	}
 	return err
 }
@@ -108,7 +137,7 @@ func (l *loggableDBTxContext) Rollback() error {
 	start := time.Now()
 	err := l.tx.Rollback()
 	if l.logDB {
-		l.logger.Debugf("[tx rollback time=%s]", time.Now().Sub(start))
+		logDBStatement(l.logger, l.trace, "tx rollback", start, "[tx rollback time=%s]")
 	}
 	return err
 }
@@ -117,33 +146,43 @@ func (l *loggableDBTxContext) NamedExec(query string, arg interface{}) (sql.Resu
 	start := time.Now()
 	res, err := l.tx.NamedExec(query, arg)
 	if l.logDB {
-		l.logger.Debugf("[tx named exec time=%s]:%s [arg]:%v", time.Now().Sub(start), query, arg)
+		logDBStatement(l.logger, l.trace, "tx named exec", start, "[tx named exec time=%s]:%s [arg]:%v", query, arg)
 	}
-	return res, interpretDBError(err, l.logDB, l.logger)
+	return res, interpretDBError(err, l.logDB, l.logger, l.trace)
 }
 
 func (l *loggableDBTxContext) NamedQuery(query string, arg interface{}) (*sqlx.Rows, error) {
 	start := time.Now()
 	res, err := l.tx.NamedQuery(query, arg)
 	if l.logDB {
-		l.logger.Debugf("[tx named query time=%s]:%s [arg]:%v", time.Now().Sub(start), query, arg)
+		logDBStatement(l.logger, l.trace, "tx named query", start, "[tx named query time=%s]:%s [arg]:%v", query, arg)
 	}
-	return res, interpretDBError(err, l.logDB, l.logger)
+	return res, interpretDBError(err, l.logDB, l.logger, l.trace)
 }
 
 func (l *loggableDBTxContext) PrepareNamed(query string) (*sqlx.NamedStmt, error) {
 	start := time.Now()
 	res, err := l.tx.PrepareNamed(query)
 	if l.logDB {
-		l.logger.Debugf("[tx preparing time=%s]:%s", time.Now().Sub(start), query)
+		logDBStatement(l.logger, l.trace, "tx preparing", start, "[tx preparing time=%s]:%s", query)
 	}
-	return res, interpretDBError(err, l.logDB, l.logger)
+	return res, interpretDBError(err, l.logDB, l.logger, l.trace)
+}
+
+// logDBStatement logs a database statement along with the operation, its duration and the trace context, if any, as structured fields
+func logDBStatement(logger *logging.Logger, trace *TraceContext, operation string, start time.Time, format string, args ...interface{}) {
+	duration := time.Now().Sub(start)
+	fields := Fields{"db_operation": operation, "duration_ms": durationMillis(duration)}
+	format, args = withTrace(trace, fields, format, append([]interface{}{duration}, args...))
+	logWithFields(logger, logging.DEBUG, fields, format, args...)
 }
 
-func interpretDBError(err error, logDB bool, logger *logging.Logger) error {
+func interpretDBError(err error, logDB bool, logger *logging.Logger, trace *TraceContext) error {
 	if err != nil {
 		if logDB {
-			logger.Debugf("[db error]:%v", err)
+			fields := Fields{"error": err}
+			format, args := withTrace(trace, fields, "[db error]:%v", []interface{}{err})
+			logWithFields(logger, logging.DEBUG, fields, format, args...)
 		}
 		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == pqUniqueViolationCode {
 			// use the table name as the resource type name
@@ -157,3 +196,13 @@ func interpretDBError(err error, logDB bool, logger *logging.Logger) error {
 	}
 	return err
 }
+
+// withTrace adds the trace context, if any, to the fields and the text message of a database log statement
+func withTrace(trace *TraceContext, fields Fields, format string, args []interface{}) (string, []interface{}) {
+	if trace == nil {
+		return format, args
+	}
+	fields["trace_id"] = trace.TraceID
+	fields["span_id"] = trace.SpanID
+	return format + " trace=%s", append(args, trace.TraceID)
+}
@@ -15,23 +15,131 @@
 package goserv
 
 import (
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 
 	"github.com/emicklei/go-restful"
+	"github.com/op/go-logging"
 )
 
 // ErrorBody struct is used when constructing a single error response body.
 type ErrorBody struct {
-	ErrorMessage string `json:"error" description:"The error message."`
-	StatusCode   int    `json:"status_code" description:"The status code."`
+	ErrorMessage string       `json:"error" description:"The error message."`
+	Code         string       `json:"code" description:"The machine readable application error code."`
+	StatusCode   int          `json:"status_code" description:"The status code."`
+	FieldErrors  []FieldError `json:"field_errors,omitempty" description:"The invalid fields of a request body."`
+	StackTrace   string       `json:"stack_trace,omitempty" description:"The stack trace of a recovered panic, only included in development."`
 }
 
 // WriteError ensures the error is appropriately handled by ensuring the correct http status code is assigned and the error message is logged.
+// The status code, message, error code and problem type are resolved from the chain of the error, so that wrapped errors, ie fmt.Errorf("loading order: %w", err), are handled as the errors they wrap.
+// Headers carried by an HTTPHeaderError, ie Retry-After, are set on the response.
+// In production mode clients only see the public message of a PublicError, never the underlying cause. The full error chain is logged instead.
+// The response body is an ErrorBody, unless the error configuration selects the RFC 7807 problem details format.
 func WriteError(response *restful.Response, err error) {
+	WriteRequestError(nil, response, err)
+}
+
+// WriteRequestError behaves like WriteError, additionally responding with RFC 7807 problem details if the request accepts application/problem+json.
+// Problem details responses reference the request URI as the problem instance and carry the trace id of the request.
+func WriteRequestError(request *restful.Request, response *restful.Response, err error) {
+	config, logger := currentErrorConfig()
 	errStatusCode := http.StatusInternalServerError
-	if httpErr, ok := err.(httpError); ok {
+	var httpErr HTTPError
+	if errors.As(err, &httpErr) {
 		errStatusCode = httpErr.StatusCode()
 	}
-	errBody := &ErrorBody{ErrorMessage: err.Error(), StatusCode: errStatusCode}
+	logError(logger, request, err, errStatusCode)
+	var headerErr HTTPHeaderError
+	if errors.As(err, &headerErr) {
+		for name, values := range headerErr.Headers() {
+			for _, value := range values {
+				response.AddHeader(name, value)
+			}
+		}
+	}
+	var fieldErrors []FieldError
+	var validationErr *ValidationError
+	if errors.As(err, &validationErr) {
+		fieldErrors = validationErr.FieldErrors
+	}
+	var stackTrace string
+	var panicErr *PanicError
+	if errors.As(err, &panicErr) && (config.IncludeStackTrace || config.isDevelopment()) {
+		stackTrace = string(panicErr.Stack)
+	}
+	if config.Format == ErrorFormatProblem || (request != nil && acceptsProblemJSON(request.HeaderParameter("Accept"))) {
+		problem := newProblemDetails(err, errStatusCode, config)
+		problem.Extensions["code"] = errorCode(err)
+		if request != nil {
+			problem.Instance = request.Request.URL.RequestURI()
+			if traceID := request.Attribute(TraceIDAttribute); traceID != nil {
+				problem.Extensions["trace_id"] = traceID
+			}
+		}
+		if len(fieldErrors) > 0 {
+			problem.Extensions["field_errors"] = fieldErrors
+		}
+		if stackTrace != "" {
+			problem.Extensions["stack_trace"] = stackTrace
+		}
+		response.WriteHeaderAndJson(errStatusCode, problem, MIMEProblemJSON)
+		return
+	}
+	errBody := &ErrorBody{ErrorMessage: clientMessage(err, errStatusCode, config), Code: errorCode(err), StatusCode: errStatusCode, FieldErrors: fieldErrors, StackTrace: stackTrace}
 	response.WriteHeaderAndJson(errStatusCode, errBody, restful.MIME_JSON)
 }
+
+// clientMessage returns the error message shown to clients. Outside of development mode this is the public message of the error, falling back to a generic message for the status code.
+func clientMessage(err error, status int, config ErrorConfig) string {
+	if config.isDevelopment() {
+		return err.Error()
+	}
+	var publicErr PublicError
+	if errors.As(err, &publicErr) {
+		return publicErr.PublicMessage()
+	}
+	var httpErr HTTPError
+	if !errors.As(err, &httpErr) {
+		status = http.StatusInternalServerError
+	}
+	return strings.ToLower(http.StatusText(status))
+}
+
+// logError logs the full error chain along with the trace id of the request. Server errors are logged as errors, client errors at the info level, subject to sampling.
+// Recovered panics are already logged by the RecoveryFilter.
+func logError(logger *logging.Logger, request *restful.Request, err error, status int) {
+	if logger == nil {
+		return
+	}
+	var panicErr *PanicError
+	if errors.As(err, &panicErr) {
+		return
+	}
+	prefix := "[error]"
+	var traceID interface{}
+	if request != nil {
+		prefix = fmt.Sprintf("[error %s %s]", request.Request.Method, request.Request.URL)
+		traceID = request.Attribute(TraceIDAttribute)
+	}
+	level := logging.INFO
+	if status >= http.StatusInternalServerError {
+		level = logging.ERROR
+	}
+	fields := Fields{"status": status, "code": errorCode(err)}
+	if traceID != nil {
+		fields["trace_id"] = fmt.Sprint(traceID)
+	}
+	logWithFields(logger, level, fields, "%s trace=%v status=%d code=%s %v (%s)", prefix, traceID, status, errorCode(err), err, errorChain(err))
+}
+
+// errorChain returns the types of the errors in the chain of an error, ie *goserv.UnauthorizedError -> *pq.Error
+func errorChain(err error) string {
+	chain := []string{}
+	for ; err != nil; err = errors.Unwrap(err) {
+		chain = append(chain, fmt.Sprintf("%T", err))
+	}
+	return strings.Join(chain, " -> ")
+}
@@ -0,0 +1,245 @@
+// Copyright 2020 Daniel Akiva
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goserv
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/emicklei/go-restful"
+	"github.com/op/go-logging"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteErrorBody(t *testing.T) {
+	// given
+	recorder := httptest.NewRecorder()
+	response := restful.NewResponse(recorder)
+
+	// when
+	WriteError(response, &ResourceNotFoundError{ResourceTypeName: "user", ResourceID: "123"})
+
+	// then
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+	assert.Equal(t, restful.MIME_JSON, recorder.Header().Get("Content-Type"))
+	body := &ErrorBody{}
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), body))
+	assert.Equal(t, http.StatusNotFound, body.StatusCode)
+	assert.Equal(t, ErrorCodeResourceNotFound, body.Code)
+}
+
+func TestWriteProblemWhenAccepted(t *testing.T) {
+	// given
+	httpReq := httptest.NewRequest(http.MethodGet, "/users/123?verbose=true", nil)
+	httpReq.Header.Set("Accept", "application/problem+json, application/json;q=0.9")
+	request := restful.NewRequest(httpReq)
+	request.SetAttribute(TraceIDAttribute, "abc")
+	recorder := httptest.NewRecorder()
+	response := restful.NewResponse(recorder)
+
+	// when
+	WriteRequestError(request, response, &ResourceNotFoundError{ResourceTypeName: "user", ResourceID: "123"})
+
+	// then
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+	assert.Equal(t, MIMEProblemJSON, recorder.Header().Get("Content-Type"))
+	problem := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &problem))
+	assert.Equal(t, "urn:goserv:problem:resource-not-found", problem["type"])
+	assert.Equal(t, "Not Found", problem["title"])
+	assert.Equal(t, float64(http.StatusNotFound), problem["status"])
+	assert.Equal(t, "/users/123?verbose=true", problem["instance"])
+	assert.Equal(t, "abc", problem["trace_id"])
+	assert.Equal(t, ErrorCodeResourceNotFound, problem["code"])
+}
+
+func TestWriteProblemByConfig(t *testing.T) {
+	// given
+	(&ErrorConfig{Format: ErrorFormatProblem, ProblemTypeBaseURI: "https://example.com/problems/"}).InitializeErrors(nil)
+	defer (&ErrorConfig{}).InitializeErrors(nil)
+	recorder := httptest.NewRecorder()
+	response := restful.NewResponse(recorder)
+
+	// when
+	WriteError(response, &IllegalArgumentError{Argument: "id"})
+
+	// then
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	problem := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &problem))
+	assert.Equal(t, "https://example.com/problems/illegal-argument", problem["type"])
+}
+
+func TestWriteProblemUntypedError(t *testing.T) {
+	// given
+	(&ErrorConfig{Format: ErrorFormatProblem}).InitializeErrors(nil)
+	defer (&ErrorConfig{}).InitializeErrors(nil)
+	recorder := httptest.NewRecorder()
+	response := restful.NewResponse(recorder)
+
+	// when
+	WriteError(response, errors.New("failure"))
+
+	// then
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+	problem := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &problem))
+	assert.Equal(t, "about:blank", problem["type"])
+	assert.Equal(t, "Internal Server Error", problem["title"])
+}
+
+func TestWriteCodedError(t *testing.T) {
+	// given
+	RegisterErrorCode("QUOTA_EXCEEDED", http.StatusPaymentRequired, "quota exceeded")
+	recorder := httptest.NewRecorder()
+	response := restful.NewResponse(recorder)
+
+	// when
+	WriteError(response, NewCodedError("QUOTA_EXCEEDED", nil))
+
+	// then
+	assert.Equal(t, http.StatusPaymentRequired, recorder.Code)
+	body := &ErrorBody{}
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), body))
+	assert.Equal(t, "QUOTA_EXCEEDED", body.Code)
+	assert.Equal(t, "quota exceeded", body.ErrorMessage)
+}
+
+func TestWriteErrorHeaders(t *testing.T) {
+	// given
+	recorder := httptest.NewRecorder()
+	response := restful.NewResponse(recorder)
+
+	// when
+	WriteError(response, &ServiceUnavailableError{RetryAfter: 1500 * time.Millisecond, Header: http.Header{"x-maintenance": {"true"}}})
+
+	// then
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+	assert.Equal(t, "2", recorder.Header().Get("Retry-After"))
+	assert.Equal(t, "true", recorder.Header().Get("X-Maintenance"))
+	body := &ErrorBody{}
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), body))
+	assert.Equal(t, ErrorCodeServiceUnavailable, body.Code)
+}
+
+func TestWritePreconditionFailedError(t *testing.T) {
+	// given
+	recorder := httptest.NewRecorder()
+	response := restful.NewResponse(recorder)
+
+	// when
+	WriteError(response, &PreconditionFailedError{ETag: `"v2"`})
+
+	// then
+	assert.Equal(t, http.StatusPreconditionFailed, recorder.Code)
+	assert.Equal(t, `"v2"`, recorder.Header().Get("ETag"))
+}
+
+func TestWriteHTTPError(t *testing.T) {
+	// given
+	recorder := httptest.NewRecorder()
+	response := restful.NewResponse(recorder)
+
+	// when
+	WriteError(response, NewHTTPError(http.StatusTeapot, "", nil))
+
+	// then
+	assert.Equal(t, http.StatusTeapot, recorder.Code)
+	body := &ErrorBody{}
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), body))
+	assert.Equal(t, "IM_A_TEAPOT", body.Code)
+	assert.Equal(t, "i'm a teapot", body.ErrorMessage)
+}
+
+func TestWriteErrorHidesCauseInProduction(t *testing.T) {
+	// given
+	backend := logging.NewMemoryBackend(10)
+	logger := logging.MustGetLogger("error_body_test")
+	logger.SetBackend(logging.AddModuleLevel(backend))
+	(&ErrorConfig{}).InitializeErrors(logger)
+	defer (&ErrorConfig{}).InitializeErrors(nil)
+	httpReq := httptest.NewRequest(http.MethodGet, "/accounts", nil)
+	request := restful.NewRequest(httpReq)
+	request.SetAttribute(TraceIDAttribute, "abc")
+	recorder := httptest.NewRecorder()
+	cause := errors.New("pq: password authentication failed for user admin")
+
+	// when
+	WriteRequestError(request, restful.NewResponse(recorder), &UnauthorizedError{Login: "bob", Err: cause})
+
+	// then
+	body := &ErrorBody{}
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), body))
+	assert.Equal(t, "unauthorized", body.ErrorMessage)
+	logged := backend.Head().Record.Message()
+	assert.Contains(t, logged, "trace=abc")
+	assert.Contains(t, logged, "bob is unauthorized: pq: password authentication failed")
+	assert.Contains(t, logged, "*goserv.UnauthorizedError -> *errors.errorString")
+}
+
+func TestWriteErrorShowsCauseInDevelopment(t *testing.T) {
+	// given
+	(&ErrorConfig{Format: ErrorFormatProblem, Mode: ErrorModeDevelopment}).InitializeErrors(nil)
+	defer (&ErrorConfig{}).InitializeErrors(nil)
+	recorder := httptest.NewRecorder()
+
+	// when
+	WriteError(restful.NewResponse(recorder), errors.New("dial tcp db.internal:5432: connection refused"))
+
+	// then
+	problem := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &problem))
+	assert.Equal(t, "dial tcp db.internal:5432: connection refused", problem["detail"])
+}
+
+func TestWriteIllegalArgumentErrorWithoutArgument(t *testing.T) {
+	// given
+	recorder := httptest.NewRecorder()
+
+	// when
+	WriteError(restful.NewResponse(recorder), &IllegalArgumentError{Err: errors.New("unexpected EOF")})
+
+	// then
+	body := &ErrorBody{}
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), body))
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Equal(t, "invalid request body", body.ErrorMessage)
+	assert.Equal(t, "illegal argument: id", (&IllegalArgumentError{Argument: "id"}).PublicMessage())
+}
+
+func TestWriteWrappedError(t *testing.T) {
+	// given
+	(&ErrorConfig{Format: ErrorFormatProblem}).InitializeErrors(nil)
+	defer (&ErrorConfig{}).InitializeErrors(nil)
+	notFound := &ResourceNotFoundError{ResourceTypeName: "user", ResourceID: "123"}
+	recorder := httptest.NewRecorder()
+
+	// when
+	WriteError(restful.NewResponse(recorder), fmt.Errorf("loading order: %w", notFound))
+
+	// then
+	assert.Equal(t, http.StatusNotFound, recorder.Code)
+	problem := map[string]interface{}{}
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &problem))
+	assert.Equal(t, "urn:goserv:problem:resource-not-found", problem["type"])
+	assert.Equal(t, float64(http.StatusNotFound), problem["status"])
+	assert.Equal(t, ErrorCodeResourceNotFound, problem["code"])
+	assert.Equal(t, notFound.PublicMessage(), problem["detail"])
+}
@@ -0,0 +1,173 @@
+// Copyright 2020 Daniel Akiva
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goserv
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+const (
+	// ErrorCodeResourceNotFound is the error code of a ResourceNotFoundError
+	ErrorCodeResourceNotFound = "RESOURCE_NOT_FOUND"
+	// ErrorCodeDuplicateResource is the error code of a DuplicateResourceError
+	ErrorCodeDuplicateResource = "DUPLICATE_RESOURCE"
+	// ErrorCodeUnauthorized is the error code of an UnauthorizedError
+	ErrorCodeUnauthorized = "UNAUTHORIZED"
+	// ErrorCodeIllegalArgument is the error code of an IllegalArgumentError
+	ErrorCodeIllegalArgument = "ILLEGAL_ARGUMENT"
+	// ErrorCodeAccessDenied is the error code of an AccessDeniedError
+	ErrorCodeAccessDenied = "ACCESS_DENIED"
+	// ErrorCodeMethodNotAllowed is the error code of a MethodNotAllowedError
+	ErrorCodeMethodNotAllowed = "METHOD_NOT_ALLOWED"
+	// ErrorCodePreconditionFailed is the error code of a PreconditionFailedError
+	ErrorCodePreconditionFailed = "PRECONDITION_FAILED"
+	// ErrorCodePayloadTooLarge is the error code of a PayloadTooLargeError
+	ErrorCodePayloadTooLarge = "PAYLOAD_TOO_LARGE"
+	// ErrorCodeUnprocessableEntity is the error code of an UnprocessableEntityError
+	ErrorCodeUnprocessableEntity = "UNPROCESSABLE_ENTITY"
+	// ErrorCodeRateLimitExceeded is the error code of a TooManyRequestsError, ie a request rejected by the RateLimitFilter
+	ErrorCodeRateLimitExceeded = "RATE_LIMIT_EXCEEDED"
+	// ErrorCodeServiceUnavailable is the error code of a ServiceUnavailableError
+	ErrorCodeServiceUnavailable = "SERVICE_UNAVAILABLE"
+	// ErrorCodeGatewayTimeout is the error code of a GatewayTimeoutError
+	ErrorCodeGatewayTimeout = "GATEWAY_TIMEOUT"
+	// ErrorCodeInternal is the error code of a PanicError and of any error that does not carry a code
+	ErrorCodeInternal = "INTERNAL_ERROR"
+)
+
+// errorCoder is implemented by errors that carry a machine readable application error code
+type errorCoder interface {
+	// ErrorCode returns the application error code
+	ErrorCode() string
+}
+
+// ErrorCodeDefinition declares an application error code along with its default message and HTTP status code.
+type ErrorCodeDefinition struct {
+	Code       string `json:"code"`
+	StatusCode int    `json:"status_code"`
+	Message    string `json:"message"`
+}
+
+var (
+	errorCatalogLock sync.RWMutex
+	errorCatalog     = map[string]ErrorCodeDefinition{}
+)
+
+func init() {
+	RegisterErrorCode(ErrorCodeResourceNotFound, http.StatusNotFound, "resource not found")
+	RegisterErrorCode(ErrorCodeDuplicateResource, http.StatusConflict, "resource already exists")
+	RegisterErrorCode(ErrorCodeUnauthorized, http.StatusUnauthorized, "unauthorized")
+	RegisterErrorCode(ErrorCodeIllegalArgument, http.StatusBadRequest, "illegal argument")
+	RegisterErrorCode(ErrorCodeAccessDenied, http.StatusForbidden, "access denied")
+	RegisterErrorCode(ErrorCodeMethodNotAllowed, http.StatusMethodNotAllowed, "method not allowed")
+	RegisterErrorCode(ErrorCodePreconditionFailed, http.StatusPreconditionFailed, "precondition failed")
+	RegisterErrorCode(ErrorCodePayloadTooLarge, http.StatusRequestEntityTooLarge, "payload too large")
+	RegisterErrorCode(ErrorCodeUnprocessableEntity, http.StatusUnprocessableEntity, "unprocessable entity")
+	RegisterErrorCode(ErrorCodeRateLimitExceeded, http.StatusTooManyRequests, "rate limit exceeded")
+	RegisterErrorCode(ErrorCodeServiceUnavailable, http.StatusServiceUnavailable, "service unavailable")
+	RegisterErrorCode(ErrorCodeGatewayTimeout, http.StatusGatewayTimeout, "gateway timeout")
+	RegisterErrorCode(ErrorCodeInternal, http.StatusInternalServerError, "internal server error")
+}
+
+// RegisterErrorCode adds an application error code to the error catalog, replacing any existing definition. Codes should be registered at startup, before InstallSwaggerService is called.
+func RegisterErrorCode(code string, statusCode int, message string) {
+	errorCatalogLock.Lock()
+	defer errorCatalogLock.Unlock()
+	errorCatalog[code] = ErrorCodeDefinition{Code: code, StatusCode: statusCode, Message: message}
+}
+
+// LookupErrorCode returns the definition of a registered error code
+func LookupErrorCode(code string) (ErrorCodeDefinition, bool) {
+	errorCatalogLock.RLock()
+	defer errorCatalogLock.RUnlock()
+	def, ok := errorCatalog[code]
+	return def, ok
+}
+
+// ErrorCodes returns all registered error code definitions, sorted by code
+func ErrorCodes() []ErrorCodeDefinition {
+	errorCatalogLock.RLock()
+	defer errorCatalogLock.RUnlock()
+	defs := make([]ErrorCodeDefinition, 0, len(errorCatalog))
+	for _, def := range errorCatalog {
+		defs = append(defs, def)
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Code < defs[j].Code })
+	return defs
+}
+
+// errorCode returns the application error code of an error, defaulting to ErrorCodeInternal
+func errorCode(err error) string {
+	var coder errorCoder
+	if errors.As(err, &coder) {
+		return coder.ErrorCode()
+	}
+	return ErrorCodeInternal
+}
+
+// CodedError represents an error identified by a service specific error code registered in the error catalog. The status code and message default to the catalog definition.
+type CodedError struct {
+	Code string
+	Err  error
+}
+
+// NewCodedError initializes a new error for a registered error code with an optional underlying error
+func NewCodedError(code string, err error) *CodedError {
+	return &CodedError{Code: code, Err: err}
+}
+
+// Error returns this error as a string
+func (c *CodedError) Error() string {
+	message := c.Code
+	if def, ok := LookupErrorCode(c.Code); ok {
+		message = def.Message
+	}
+	if c.Err != nil {
+		return fmt.Sprintf("%v: %v", message, c.Err)
+	}
+	return message
+}
+
+// Unwrap returns the underlying error
+func (c *CodedError) Unwrap() error { return c.Err }
+
+// StatusCode returns the HTTP status code registered for the error code, or 500 if the code is not registered
+func (c *CodedError) StatusCode() int {
+	if def, ok := LookupErrorCode(c.Code); ok {
+		return def.StatusCode
+	}
+	return http.StatusInternalServerError
+}
+
+// ErrorCode returns the application error code
+func (c *CodedError) ErrorCode() string { return c.Code }
+
+// PublicMessage returns the message registered for the error code, omitting the underlying error
+func (c *CodedError) PublicMessage() string {
+	if def, ok := LookupErrorCode(c.Code); ok {
+		return def.Message
+	}
+	return c.Code
+}
+
+// ProblemType returns the RFC 7807 problem type name derived from the error code
+func (c *CodedError) ProblemType() string {
+	return strings.ReplaceAll(strings.ToLower(c.Code), "_", "-")
+}
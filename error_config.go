@@ -0,0 +1,82 @@
+// Copyright 2020 Daniel Akiva
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goserv
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/op/go-logging"
+)
+
+const (
+	// ErrorFormatErrorBody renders errors as an ErrorBody, unless the client asks for problem details
+	ErrorFormatErrorBody = "ERROR_BODY"
+	// ErrorFormatProblem always renders errors as RFC 7807 problem details
+	ErrorFormatProblem = "PROBLEM"
+
+	// ErrorModeProduction shows clients the public message of an error only
+	ErrorModeProduction = "PRODUCTION"
+	// ErrorModeDevelopment shows clients the full error chain along with the stack trace of recovered panics
+	ErrorModeDevelopment = "DEVELOPMENT"
+)
+
+var (
+	errorConfigLock sync.RWMutex
+	errorConfig     = ErrorConfig{}
+	errorLogger     *logging.Logger
+)
+
+// ErrorConfig controls how errors are rendered by WriteError.
+type ErrorConfig struct {
+	// Format is one of [ERROR_BODY, PROBLEM], defaulting to ERROR_BODY
+	Format string `json:"format"`
+	// ProblemTypeBaseURI is prepended to the problem type of goserv errors, defaulting to DefaultProblemTypeBaseURI
+	ProblemTypeBaseURI string `json:"problem_type_base_uri"`
+	// Mode is one of [PRODUCTION, DEVELOPMENT], defaulting to PRODUCTION
+	Mode string `json:"mode"`
+	// IncludeStackTrace includes the stack trace of recovered panics in error responses. Intended for development only, implied by the DEVELOPMENT mode.
+	IncludeStackTrace bool `json:"include_stack_trace"`
+}
+
+// Validate ensures the configuration is valid
+func (e *ErrorConfig) Validate() error {
+	if e.Format != "" && e.Format != ErrorFormatErrorBody && e.Format != ErrorFormatProblem {
+		return fmt.Errorf("invalid error format %s, must be one of [ERROR_BODY, PROBLEM]", e.Format)
+	}
+	if e.Mode != "" && e.Mode != ErrorModeProduction && e.Mode != ErrorModeDevelopment {
+		return fmt.Errorf("invalid error mode %s, must be one of [PRODUCTION, DEVELOPMENT]", e.Mode)
+	}
+	return nil
+}
+
+// InitializeErrors installs this configuration for all subsequent WriteError calls. Written errors are logged along with their full error chain to the logger, if not nil.
+func (e *ErrorConfig) InitializeErrors(logger *logging.Logger) {
+	errorConfigLock.Lock()
+	defer errorConfigLock.Unlock()
+	errorConfig = *e
+	errorLogger = logger
+}
+
+// isDevelopment returns true if clients are shown the internal details of errors
+func (e ErrorConfig) isDevelopment() bool {
+	return e.Mode == ErrorModeDevelopment
+}
+
+func currentErrorConfig() (ErrorConfig, *logging.Logger) {
+	errorConfigLock.RLock()
+	defer errorConfigLock.RUnlock()
+	return errorConfig, errorLogger
+}
@@ -17,14 +17,32 @@ package goserv
 import (
 	"fmt"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 )
 
-// httpError represents an error that has an http status code.
-type httpError interface {
+// HTTPError represents an error that has an http status code.
+type HTTPError interface {
+	error
 	// StatusCode returns the HTTP status code appropriate for the error type
 	StatusCode() int
 }
 
+// PublicError represents an error that exposes a message safe to show to clients, separate from its internal cause.
+type PublicError interface {
+	error
+	// PublicMessage returns the message shown to clients
+	PublicMessage() string
+}
+
+// HTTPHeaderError represents an error that carries headers to set on the error response, ie Retry-After.
+type HTTPHeaderError interface {
+	HTTPError
+	// Headers returns the headers to set on the error response
+	Headers() http.Header
+}
+
 // ResourceNotFoundError represents an error when a resource could not be found (404).
 type ResourceNotFoundError struct {
 	ResourceID       string
@@ -47,6 +65,17 @@ func (r *ResourceNotFoundError) Unwrap() error { return r.Err }
 // StatusCode returns the HTTP status code appropriate for the error type
 func (r *ResourceNotFoundError) StatusCode() int { return http.StatusNotFound }
 
+// ProblemType returns the RFC 7807 problem type name for the error type
+func (r *ResourceNotFoundError) ProblemType() string { return "resource-not-found" }
+
+// ErrorCode returns the application error code for the error type
+func (r *ResourceNotFoundError) ErrorCode() string { return ErrorCodeResourceNotFound }
+
+// PublicMessage returns the message shown to clients, omitting the underlying error
+func (r *ResourceNotFoundError) PublicMessage() string {
+	return fmt.Sprintf("%v resource %v not found", r.ResourceTypeName, r.ResourceID)
+}
+
 // DuplicateResourceError represents a duplicate resource signal (409) typically raised on resource creation
 type DuplicateResourceError struct {
 	ResourceTypeName string
@@ -68,6 +97,17 @@ func (d *DuplicateResourceError) Unwrap() error { return d.Err }
 // StatusCode returns the HTTP status code appropriate for the error type
 func (d *DuplicateResourceError) StatusCode() int { return http.StatusConflict }
 
+// ProblemType returns the RFC 7807 problem type name for the error type
+func (d *DuplicateResourceError) ProblemType() string { return "duplicate-resource" }
+
+// ErrorCode returns the application error code for the error type
+func (d *DuplicateResourceError) ErrorCode() string { return ErrorCodeDuplicateResource }
+
+// PublicMessage returns the message shown to clients, omitting the underlying error
+func (d *DuplicateResourceError) PublicMessage() string {
+	return fmt.Sprintf("%v resource already exists", d.ResourceTypeName)
+}
+
 // UnauthorizedError represents unauthorized access to the system
 type UnauthorizedError struct {
 	Login string
@@ -89,6 +129,17 @@ func (u *UnauthorizedError) Unwrap() error { return u.Err }
 // StatusCode returns the HTTP status code appropriate for the error type
 func (u *UnauthorizedError) StatusCode() int { return http.StatusUnauthorized }
 
+// ProblemType returns the RFC 7807 problem type name for the error type
+func (u *UnauthorizedError) ProblemType() string { return "unauthorized" }
+
+// ErrorCode returns the application error code for the error type
+func (u *UnauthorizedError) ErrorCode() string { return ErrorCodeUnauthorized }
+
+// PublicMessage returns the message shown to clients, omitting the underlying error
+func (u *UnauthorizedError) PublicMessage() string {
+	return "unauthorized"
+}
+
 // IllegalArgumentError represents a bad request argument (400)
 type IllegalArgumentError struct {
 	Argument string
@@ -110,6 +161,20 @@ func (i *IllegalArgumentError) Unwrap() error { return i.Err }
 // StatusCode returns the HTTP status code appropriate for the error type
 func (i *IllegalArgumentError) StatusCode() int { return http.StatusBadRequest }
 
+// ProblemType returns the RFC 7807 problem type name for the error type
+func (i *IllegalArgumentError) ProblemType() string { return "illegal-argument" }
+
+// ErrorCode returns the application error code for the error type
+func (i *IllegalArgumentError) ErrorCode() string { return ErrorCodeIllegalArgument }
+
+// PublicMessage returns the message shown to clients, omitting the underlying error. Errors without an argument typically stem from a malformed request body.
+func (i *IllegalArgumentError) PublicMessage() string {
+	if i.Argument == "" {
+		return "invalid request body"
+	}
+	return fmt.Sprintf("illegal argument: %v", i.Argument)
+}
+
 // AccessDeniedError represents unauthorized access to a specific system function or resource
 type AccessDeniedError struct {
 	Err error
@@ -129,3 +194,368 @@ func (a *AccessDeniedError) Unwrap() error { return a.Err }
 
 // StatusCode returns the HTTP status code appropriate for the error type
 func (a *AccessDeniedError) StatusCode() int { return http.StatusForbidden }
+
+// ProblemType returns the RFC 7807 problem type name for the error type
+func (a *AccessDeniedError) ProblemType() string { return "access-denied" }
+
+// ErrorCode returns the application error code for the error type
+func (a *AccessDeniedError) ErrorCode() string { return ErrorCodeAccessDenied }
+
+// PublicMessage returns the message shown to clients, omitting the underlying error
+func (a *AccessDeniedError) PublicMessage() string {
+	return "access denied"
+}
+
+// PanicError represents a panic recovered while processing a request (500)
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+// Error returns this error as a string
+func (p *PanicError) Error() string {
+	return fmt.Sprintf("internal server error: %v", p.Value)
+}
+
+// StatusCode returns the HTTP status code appropriate for the error type
+func (p *PanicError) StatusCode() int { return http.StatusInternalServerError }
+
+// ProblemType returns the RFC 7807 problem type name for the error type
+func (p *PanicError) ProblemType() string { return "internal-server-error" }
+
+// ErrorCode returns the application error code for the error type
+func (p *PanicError) ErrorCode() string { return ErrorCodeInternal }
+
+// PublicMessage returns the message shown to clients, omitting the underlying error
+func (p *PanicError) PublicMessage() string {
+	return "internal server error"
+}
+
+// MethodNotAllowedError represents a request method that is not supported by the target resource (405)
+type MethodNotAllowedError struct {
+	Method  string
+	Allowed []string
+	Err     error
+}
+
+// Error returns this error as a string
+func (m *MethodNotAllowedError) Error() string {
+	message := ""
+	if m.Err != nil {
+		message = m.Err.Error()
+	}
+	return fmt.Sprintf("method %v not allowed: %v", m.Method, message)
+}
+
+// Unwrap returns the underlying error
+func (m *MethodNotAllowedError) Unwrap() error { return m.Err }
+
+// StatusCode returns the HTTP status code appropriate for the error type
+func (m *MethodNotAllowedError) StatusCode() int { return http.StatusMethodNotAllowed }
+
+// ProblemType returns the RFC 7807 problem type name for the error type
+func (m *MethodNotAllowedError) ProblemType() string { return "method-not-allowed" }
+
+// ErrorCode returns the application error code for the error type
+func (m *MethodNotAllowedError) ErrorCode() string { return ErrorCodeMethodNotAllowed }
+
+// PublicMessage returns the message shown to clients, omitting the underlying error
+func (m *MethodNotAllowedError) PublicMessage() string {
+	return fmt.Sprintf("method %v not allowed", m.Method)
+}
+
+// Headers returns the Allow header listing the supported methods
+func (m *MethodNotAllowedError) Headers() http.Header {
+	headers := http.Header{}
+	if len(m.Allowed) > 0 {
+		headers.Set("Allow", strings.Join(m.Allowed, ", "))
+	}
+	return headers
+}
+
+// PreconditionFailedError represents a conditional request whose precondition, ie If-Match, did not hold (412). ETag is the current entity tag of the resource.
+type PreconditionFailedError struct {
+	ETag string
+	Err  error
+}
+
+// Error returns this error as a string
+func (p *PreconditionFailedError) Error() string {
+	message := ""
+	if p.Err != nil {
+		message = p.Err.Error()
+	}
+	return fmt.Sprintf("precondition failed: %v", message)
+}
+
+// Unwrap returns the underlying error
+func (p *PreconditionFailedError) Unwrap() error { return p.Err }
+
+// StatusCode returns the HTTP status code appropriate for the error type
+func (p *PreconditionFailedError) StatusCode() int { return http.StatusPreconditionFailed }
+
+// ProblemType returns the RFC 7807 problem type name for the error type
+func (p *PreconditionFailedError) ProblemType() string { return "precondition-failed" }
+
+// ErrorCode returns the application error code for the error type
+func (p *PreconditionFailedError) ErrorCode() string { return ErrorCodePreconditionFailed }
+
+// PublicMessage returns the message shown to clients, omitting the underlying error
+func (p *PreconditionFailedError) PublicMessage() string {
+	return "precondition failed"
+}
+
+// Headers returns the ETag header holding the current entity tag
+func (p *PreconditionFailedError) Headers() http.Header {
+	headers := http.Header{}
+	if p.ETag != "" {
+		headers.Set("ETag", p.ETag)
+	}
+	return headers
+}
+
+// PayloadTooLargeError represents a request body exceeding the accepted size (413)
+type PayloadTooLargeError struct {
+	MaxBytes int64
+	Err      error
+}
+
+// Error returns this error as a string
+func (p *PayloadTooLargeError) Error() string {
+	message := ""
+	if p.Err != nil {
+		message = p.Err.Error()
+	}
+	return fmt.Sprintf("payload exceeds %v bytes: %v", p.MaxBytes, message)
+}
+
+// Unwrap returns the underlying error
+func (p *PayloadTooLargeError) Unwrap() error { return p.Err }
+
+// StatusCode returns the HTTP status code appropriate for the error type
+func (p *PayloadTooLargeError) StatusCode() int { return http.StatusRequestEntityTooLarge }
+
+// ProblemType returns the RFC 7807 problem type name for the error type
+func (p *PayloadTooLargeError) ProblemType() string { return "payload-too-large" }
+
+// ErrorCode returns the application error code for the error type
+func (p *PayloadTooLargeError) ErrorCode() string { return ErrorCodePayloadTooLarge }
+
+// PublicMessage returns the message shown to clients, omitting the underlying error
+func (p *PayloadTooLargeError) PublicMessage() string {
+	return fmt.Sprintf("payload exceeds %v bytes", p.MaxBytes)
+}
+
+// UnprocessableEntityError represents a well formed request that cannot be processed, ie it violates a business rule (422)
+type UnprocessableEntityError struct {
+	Err error
+}
+
+// Error returns this error as a string
+func (u *UnprocessableEntityError) Error() string {
+	message := ""
+	if u.Err != nil {
+		message = u.Err.Error()
+	}
+	return fmt.Sprintf("unprocessable entity: %v", message)
+}
+
+// Unwrap returns the underlying error
+func (u *UnprocessableEntityError) Unwrap() error { return u.Err }
+
+// StatusCode returns the HTTP status code appropriate for the error type
+func (u *UnprocessableEntityError) StatusCode() int { return http.StatusUnprocessableEntity }
+
+// ProblemType returns the RFC 7807 problem type name for the error type
+func (u *UnprocessableEntityError) ProblemType() string { return "unprocessable-entity" }
+
+// ErrorCode returns the application error code for the error type
+func (u *UnprocessableEntityError) ErrorCode() string { return ErrorCodeUnprocessableEntity }
+
+// PublicMessage returns the message shown to clients, omitting the underlying error
+func (u *UnprocessableEntityError) PublicMessage() string {
+	return "unprocessable entity"
+}
+
+// TooManyRequestsError represents a client that exceeded its request rate (429). RetryAfter, if set, is rendered as the Retry-After header.
+type TooManyRequestsError struct {
+	RetryAfter time.Duration
+	Err        error
+}
+
+// Error returns this error as a string
+func (t *TooManyRequestsError) Error() string {
+	message := ""
+	if t.Err != nil {
+		message = t.Err.Error()
+	}
+	return fmt.Sprintf("too many requests: %v", message)
+}
+
+// Unwrap returns the underlying error
+func (t *TooManyRequestsError) Unwrap() error { return t.Err }
+
+// StatusCode returns the HTTP status code appropriate for the error type
+func (t *TooManyRequestsError) StatusCode() int { return http.StatusTooManyRequests }
+
+// ProblemType returns the RFC 7807 problem type name for the error type
+func (t *TooManyRequestsError) ProblemType() string { return "too-many-requests" }
+
+// ErrorCode returns the application error code for the error type
+func (t *TooManyRequestsError) ErrorCode() string { return ErrorCodeRateLimitExceeded }
+
+// PublicMessage returns the message shown to clients, omitting the underlying error
+func (t *TooManyRequestsError) PublicMessage() string {
+	return "too many requests"
+}
+
+// Headers returns the Retry-After header
+func (t *TooManyRequestsError) Headers() http.Header {
+	return retryAfterHeaders(t.RetryAfter)
+}
+
+// ServiceUnavailableError represents a service that is temporarily unable to handle requests, ie during maintenance or overload (503).
+// RetryAfter, if set, is rendered as the Retry-After header along with any additional Header values.
+type ServiceUnavailableError struct {
+	RetryAfter time.Duration
+	Header     http.Header
+	Err        error
+}
+
+// Error returns this error as a string
+func (s *ServiceUnavailableError) Error() string {
+	message := ""
+	if s.Err != nil {
+		message = s.Err.Error()
+	}
+	return fmt.Sprintf("service unavailable: %v", message)
+}
+
+// Unwrap returns the underlying error
+func (s *ServiceUnavailableError) Unwrap() error { return s.Err }
+
+// StatusCode returns the HTTP status code appropriate for the error type
+func (s *ServiceUnavailableError) StatusCode() int { return http.StatusServiceUnavailable }
+
+// ProblemType returns the RFC 7807 problem type name for the error type
+func (s *ServiceUnavailableError) ProblemType() string { return "service-unavailable" }
+
+// ErrorCode returns the application error code for the error type
+func (s *ServiceUnavailableError) ErrorCode() string { return ErrorCodeServiceUnavailable }
+
+// PublicMessage returns the message shown to clients, omitting the underlying error
+func (s *ServiceUnavailableError) PublicMessage() string {
+	return "service unavailable"
+}
+
+// Headers returns the Retry-After header along with the additional headers
+func (s *ServiceUnavailableError) Headers() http.Header {
+	headers := retryAfterHeaders(s.RetryAfter)
+	for name, values := range s.Header {
+		headers[http.CanonicalHeaderKey(name)] = values
+	}
+	return headers
+}
+
+// GatewayTimeoutError represents an upstream service that did not respond in time (504)
+type GatewayTimeoutError struct {
+	Upstream string
+	Err      error
+}
+
+// Error returns this error as a string
+func (g *GatewayTimeoutError) Error() string {
+	message := ""
+	if g.Err != nil {
+		message = g.Err.Error()
+	}
+	return fmt.Sprintf("upstream %v timed out: %v", g.Upstream, message)
+}
+
+// Unwrap returns the underlying error
+func (g *GatewayTimeoutError) Unwrap() error { return g.Err }
+
+// StatusCode returns the HTTP status code appropriate for the error type
+func (g *GatewayTimeoutError) StatusCode() int { return http.StatusGatewayTimeout }
+
+// ProblemType returns the RFC 7807 problem type name for the error type
+func (g *GatewayTimeoutError) ProblemType() string { return "gateway-timeout" }
+
+// ErrorCode returns the application error code for the error type
+func (g *GatewayTimeoutError) ErrorCode() string { return ErrorCodeGatewayTimeout }
+
+// PublicMessage returns the message shown to clients, omitting the underlying error
+func (g *GatewayTimeoutError) PublicMessage() string {
+	return fmt.Sprintf("upstream %v timed out", g.Upstream)
+}
+
+// StatusError represents an error with an arbitrary HTTP status code, for status codes without a dedicated error type.
+// Code and Message default to values derived from the status code, and Header holds headers to set on the error response.
+type StatusError struct {
+	Status  int
+	Code    string
+	Message string
+	Header  http.Header
+	Err     error
+}
+
+// NewHTTPError initializes a new error for a status code with an optional underlying error
+func NewHTTPError(statusCode int, message string, err error) *StatusError {
+	return &StatusError{Status: statusCode, Message: message, Err: err}
+}
+
+// Error returns this error as a string
+func (s *StatusError) Error() string {
+	message := s.Message
+	if message == "" {
+		message = strings.ToLower(http.StatusText(s.Status))
+	}
+	if s.Err != nil {
+		return fmt.Sprintf("%v: %v", message, s.Err)
+	}
+	return message
+}
+
+// Unwrap returns the underlying error
+func (s *StatusError) Unwrap() error { return s.Err }
+
+// StatusCode returns the HTTP status code of the error, or 500 if the status code is not set
+func (s *StatusError) StatusCode() int {
+	if s.Status == 0 {
+		return http.StatusInternalServerError
+	}
+	return s.Status
+}
+
+// ProblemType returns the RFC 7807 problem type name derived from the status code
+func (s *StatusError) ProblemType() string {
+	return strings.ReplaceAll(strings.ToLower(http.StatusText(s.StatusCode())), " ", "-")
+}
+
+// ErrorCode returns the application error code, defaulting to one derived from the status code
+func (s *StatusError) ErrorCode() string {
+	if s.Code != "" {
+		return s.Code
+	}
+	code := strings.ToUpper(http.StatusText(s.StatusCode()))
+	return strings.NewReplacer(" ", "_", "-", "_", "'", "").Replace(code)
+}
+
+// PublicMessage returns the message shown to clients, omitting the underlying error
+func (s *StatusError) PublicMessage() string {
+	if s.Message != "" {
+		return s.Message
+	}
+	return strings.ToLower(http.StatusText(s.StatusCode()))
+}
+
+// Headers returns the headers to set on the error response
+func (s *StatusError) Headers() http.Header { return s.Header }
+
+func retryAfterHeaders(retryAfter time.Duration) http.Header {
+	headers := http.Header{}
+	if retryAfter > 0 {
+		headers.Set("Retry-After", strconv.Itoa(ceilSeconds(retryAfter)))
+	}
+	return headers
+}
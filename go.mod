@@ -3,15 +3,16 @@ module github.com/dakiva/goserv
 go 1.14
 
 require (
+	github.com/andybalholm/brotli v1.0.0
 	github.com/dakiva/dbx v1.2.0
 	github.com/dgrijalva/jwt-go v3.2.0+incompatible
 	github.com/emicklei/go-restful v2.12.0+incompatible
 	github.com/emicklei/go-restful-openapi v1.3.0
 	github.com/go-openapi/spec v0.19.7
-	github.com/google/uuid v1.1.1
 	github.com/jmoiron/sqlx v1.2.0
 	github.com/json-iterator/go v1.1.9 // indirect
 	github.com/lib/pq v1.3.0
 	github.com/op/go-logging v0.0.0-20160315200505-970db520ece7
 	github.com/stretchr/testify v1.5.1
+	golang.org/x/sys v0.0.0-20200323222414-85ca7c5b95cd
 )
@@ -0,0 +1,102 @@
+// Copyright 2020 Daniel Akiva
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goserv
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// HealthChecker represents a single named health check.
+type HealthChecker interface {
+	// Name returns the name of the check, reported alongside its result
+	Name() string
+	// Check runs the check, returning an error if the checked dependency is unhealthy. Implementations must honor the context deadline.
+	Check(ctx context.Context) error
+}
+
+// NewHealthChecker adapts a named function to a HealthChecker.
+func NewHealthChecker(name string, check func(ctx context.Context) error) HealthChecker {
+	return &funcHealthChecker{name: name, check: check}
+}
+
+type funcHealthChecker struct {
+	name  string
+	check func(ctx context.Context) error
+}
+
+func (f *funcHealthChecker) Name() string                    { return f.name }
+func (f *funcHealthChecker) Check(ctx context.Context) error { return f.check(ctx) }
+
+// NewDBHealthChecker returns a check that pings the database using the configured connection pool.
+func NewDBHealthChecker(db *sqlx.DB) HealthChecker {
+	return NewHealthChecker("db", func(ctx context.Context) error {
+		return db.PingContext(ctx)
+	})
+}
+
+// NewDiskSpaceHealthChecker returns a check that fails if the file system containing the path has less than minFreeBytes available.
+// The check is supported on Linux, macOS and FreeBSD, elsewhere it always fails.
+func NewDiskSpaceHealthChecker(path string, minFreeBytes uint64) HealthChecker {
+	return NewHealthChecker("disk:"+path, func(ctx context.Context) error {
+		free, err := availableDiskSpace(path)
+		if err != nil {
+			return err
+		}
+		if free < minFreeBytes {
+			return fmt.Errorf("%d bytes available, %d bytes required", free, minFreeBytes)
+		}
+		return nil
+	})
+}
+
+// DiskSpaceHealthCheckers returns a disk space check for the directory of every FILE backend in the logging configuration.
+func (l *LoggingConfig) DiskSpaceHealthCheckers(minFreeBytes uint64) []HealthChecker {
+	checkers := []HealthChecker{}
+	for _, b := range l.Backends {
+		if strings.EqualFold(b.BackendName, "FILE") && b.FilePath != "" {
+			checkers = append(checkers, NewDiskSpaceHealthChecker(filepath.Dir(b.FilePath), minFreeBytes))
+		}
+	}
+	return checkers
+}
+
+// NewOIDCIssuerHealthChecker returns a check that fetches the OpenID Connect discovery document of the configured issuer. If client is nil, http.DefaultClient is used.
+func NewOIDCIssuerHealthChecker(config *OpenIDConnectClientConfig, client *http.Client) HealthChecker {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	discoveryURL := strings.TrimSuffix(config.Issuer, "/") + "/.well-known/openid-configuration"
+	return NewHealthChecker("oidc_issuer", func(ctx context.Context) error {
+		req, err := http.NewRequest(http.MethodGet, discoveryURL, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req.WithContext(ctx))
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("issuer discovery returned status %d", resp.StatusCode)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,186 @@
+// Copyright 2020 Daniel Akiva
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goserv
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/emicklei/go-restful"
+)
+
+const (
+	// HealthStatusUp signals a passing check
+	HealthStatusUp = "UP"
+	// HealthStatusDown signals a failing check
+	HealthStatusDown = "DOWN"
+
+	// DefaultHealthCheckTimeout is the default maximum duration of a single check
+	DefaultHealthCheckTimeout = 5 * time.Second
+	// DefaultHealthCheckCacheTTL is the default duration a check result is reused before the check is run again
+	DefaultHealthCheckCacheTTL = 10 * time.Second
+)
+
+// HealthCheckResult represents the outcome of a single health check.
+type HealthCheckResult struct {
+	Name          string     `json:"name" description:"The name of the check."`
+	Status        string     `json:"status" description:"The status of the check, either UP or DOWN."`
+	Latency       string     `json:"latency" description:"The time taken to run the check."`
+	CheckedAt     time.Time  `json:"checked_at" description:"Timestamp in UTC of when the check was last run."`
+	LastError     string     `json:"last_error,omitempty" description:"The most recent error reported by the check."`
+	LastErrorTime *time.Time `json:"last_error_time,omitempty" description:"Timestamp in UTC of the most recent error reported by the check."`
+}
+
+// HealthResource represents the aggregated result of a set of health checks, exposed as an API.
+type HealthResource struct {
+	Status string              `json:"status" description:"The overall status, UP only if all checks are UP."`
+	Checks []HealthCheckResult `json:"checks" description:"The individual check results."`
+}
+
+// HealthService runs liveness and readiness checks and exposes them as a go-restful WebService. Checks run in parallel, each bounded by Timeout, and results are cached for CacheTTL.
+type HealthService struct {
+	Timeout   time.Duration
+	CacheTTL  time.Duration
+	liveness  []*cachedHealthCheck
+	readiness []*cachedHealthCheck
+}
+
+// NewHealthService initializes a new instance using the default timeout and cache ttl
+func NewHealthService() *HealthService {
+	return &HealthService{
+		Timeout:  DefaultHealthCheckTimeout,
+		CacheTTL: DefaultHealthCheckCacheTTL,
+	}
+}
+
+// AddLivenessCheck adds checks that determine whether the service is running. A failing liveness check typically results in a restart.
+func (h *HealthService) AddLivenessCheck(checkers ...HealthChecker) {
+	for _, c := range checkers {
+		h.liveness = append(h.liveness, &cachedHealthCheck{checker: c})
+	}
+}
+
+// AddReadinessCheck adds checks that determine whether the service is able to serve traffic.
+func (h *HealthService) AddReadinessCheck(checkers ...HealthChecker) {
+	for _, c := range checkers {
+		h.readiness = append(h.readiness, &cachedHealthCheck{checker: c})
+	}
+}
+
+// Liveness runs (or returns cached results of) the liveness checks
+func (h *HealthService) Liveness() *HealthResource {
+	return h.run(h.liveness)
+}
+
+// Readiness runs (or returns cached results of) the readiness checks
+func (h *HealthService) Readiness() *HealthResource {
+	return h.run(h.readiness)
+}
+
+// WebService returns a WebService exposing GET /health/live and GET /health/ready. A 200 is returned if all checks pass, 503 otherwise.
+func (h *HealthService) WebService() *restful.WebService {
+	ws := new(restful.WebService)
+	ws.Path("/health").Produces(restful.MIME_JSON)
+	ws.Route(ws.GET("/live").To(h.handleLiveness).
+		Doc("Liveness checks").
+		Writes(HealthResource{}).
+		Returns(http.StatusOK, "OK", HealthResource{}).
+		Returns(http.StatusServiceUnavailable, "Service Unavailable", HealthResource{}))
+	ws.Route(ws.GET("/ready").To(h.handleReadiness).
+		Doc("Readiness checks").
+		Writes(HealthResource{}).
+		Returns(http.StatusOK, "OK", HealthResource{}).
+		Returns(http.StatusServiceUnavailable, "Service Unavailable", HealthResource{}))
+	return ws
+}
+
+func (h *HealthService) handleLiveness(request *restful.Request, response *restful.Response) {
+	writeHealthResource(response, h.Liveness())
+}
+
+func (h *HealthService) handleReadiness(request *restful.Request, response *restful.Response) {
+	writeHealthResource(response, h.Readiness())
+}
+
+func writeHealthResource(response *restful.Response, resource *HealthResource) {
+	status := http.StatusOK
+	if resource.Status != HealthStatusUp {
+		status = http.StatusServiceUnavailable
+	}
+	response.WriteHeaderAndJson(status, resource, restful.MIME_JSON)
+}
+
+func (h *HealthService) run(checks []*cachedHealthCheck) *HealthResource {
+	resource := &HealthResource{Status: HealthStatusUp, Checks: make([]HealthCheckResult, len(checks))}
+	var wg sync.WaitGroup
+	for i, c := range checks {
+		wg.Add(1)
+		go func(i int, c *cachedHealthCheck) {
+			defer wg.Done()
+			resource.Checks[i] = c.result(h.Timeout, h.CacheTTL)
+		}(i, c)
+	}
+	wg.Wait()
+	for _, result := range resource.Checks {
+		if result.Status != HealthStatusUp {
+			resource.Status = HealthStatusDown
+		}
+	}
+	return resource
+}
+
+// cachedHealthCheck guards a checker so that concurrent callers share a single run and its result until the cache expires.
+type cachedHealthCheck struct {
+	checker HealthChecker
+	mu      sync.Mutex
+	last    HealthCheckResult
+	expires time.Time
+}
+
+func (c *cachedHealthCheck) result(timeout, ttl time.Duration) HealthCheckResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := time.Now()
+	if now.Before(c.expires) {
+		return c.last
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- c.checker.Check(ctx)
+	}()
+	var err error
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	c.last.Name = c.checker.Name()
+	c.last.Latency = time.Since(now).String()
+	c.last.CheckedAt = now.UTC()
+	if err != nil {
+		errTime := now.UTC()
+		c.last.Status = HealthStatusDown
+		c.last.LastError = err.Error()
+		c.last.LastErrorTime = &errTime
+	} else {
+		c.last.Status = HealthStatusUp
+	}
+	c.expires = now.Add(ttl)
+	return c.last
+}
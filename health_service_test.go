@@ -0,0 +1,153 @@
+// Copyright 2020 Daniel Akiva
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goserv
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/emicklei/go-restful"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadinessAllChecksPass(t *testing.T) {
+	// given
+	service := NewHealthService()
+	service.AddReadinessCheck(
+		NewHealthChecker("a", func(ctx context.Context) error { return nil }),
+		NewHealthChecker("b", func(ctx context.Context) error { return nil }),
+	)
+
+	// when
+	resource := service.Readiness()
+
+	// then
+	assert.Equal(t, HealthStatusUp, resource.Status)
+	assert.Len(t, resource.Checks, 2)
+	assert.Equal(t, "a", resource.Checks[0].Name)
+	assert.Equal(t, "b", resource.Checks[1].Name)
+}
+
+func TestReadinessFailingCheck(t *testing.T) {
+	// given
+	service := NewHealthService()
+	service.AddReadinessCheck(
+		NewHealthChecker("a", func(ctx context.Context) error { return nil }),
+		NewHealthChecker("b", func(ctx context.Context) error { return errors.New("unreachable") }),
+	)
+
+	// when
+	resource := service.Readiness()
+
+	// then
+	assert.Equal(t, HealthStatusDown, resource.Status)
+	assert.Equal(t, HealthStatusDown, resource.Checks[1].Status)
+	assert.Equal(t, "unreachable", resource.Checks[1].LastError)
+	assert.NotNil(t, resource.Checks[1].LastErrorTime)
+}
+
+func TestHealthCheckTimeout(t *testing.T) {
+	// given
+	service := NewHealthService()
+	service.Timeout = 10 * time.Millisecond
+	service.AddReadinessCheck(NewHealthChecker("slow", func(ctx context.Context) error {
+		time.Sleep(time.Second)
+		return nil
+	}))
+
+	// when
+	start := time.Now()
+	resource := service.Readiness()
+
+	// then
+	assert.True(t, time.Since(start) < time.Second)
+	assert.Equal(t, HealthStatusDown, resource.Status)
+	assert.Equal(t, context.DeadlineExceeded.Error(), resource.Checks[0].LastError)
+}
+
+func TestHealthCheckResultIsCached(t *testing.T) {
+	// given
+	calls := 0
+	service := NewHealthService()
+	service.AddLivenessCheck(NewHealthChecker("counting", func(ctx context.Context) error {
+		calls++
+		return nil
+	}))
+
+	// when
+	service.Liveness()
+	service.Liveness()
+
+	// then
+	assert.Equal(t, 1, calls)
+}
+
+func TestReadinessRoute(t *testing.T) {
+	// given
+	service := NewHealthService()
+	service.AddReadinessCheck(NewHealthChecker("a", func(ctx context.Context) error { return errors.New("down") }))
+	container := restful.NewContainer()
+	container.Add(service.WebService())
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/health/ready", nil)
+
+	// when
+	container.ServeHTTP(recorder, req)
+
+	// then
+	assert.Equal(t, http.StatusServiceUnavailable, recorder.Code)
+	resource := &HealthResource{}
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), resource))
+	assert.Equal(t, HealthStatusDown, resource.Status)
+}
+
+func TestOIDCIssuerHealthChecker(t *testing.T) {
+	// given
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/.well-known/openid-configuration" {
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+	checker := NewOIDCIssuerHealthChecker(&OpenIDConnectClientConfig{Issuer: server.URL + "/"}, nil)
+
+	// when
+	err := checker.Check(context.Background())
+
+	// then
+	assert.NoError(t, err)
+}
+
+func TestDiskSpaceHealthChecker(t *testing.T) {
+	// given
+	dir, _ := ioutil.TempDir("", "disk_space_test")
+	defer os.RemoveAll(dir)
+
+	// when
+	available := NewDiskSpaceHealthChecker(dir, 1).Check(context.Background())
+	insufficient := NewDiskSpaceHealthChecker(dir, math.MaxUint64).Check(context.Background())
+
+	// then
+	assert.NoError(t, available)
+	assert.Error(t, insufficient)
+}
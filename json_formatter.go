@@ -0,0 +1,75 @@
+// Copyright 2020 Daniel Akiva
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goserv
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/op/go-logging"
+)
+
+// JSONFormatter is an op/go-logging formatter that renders each record as a single line JSON object holding the timestamp, level, module, file and message of the record.
+// Fields logged through a FieldLogger, the RestfulLoggingFilter or the database wrappers are emitted as additional members. Fields never override the standard members.
+type JSONFormatter struct{}
+
+// NewJSONFormatter initializes a new JSON formatter
+func NewJSONFormatter() *JSONFormatter {
+	return &JSONFormatter{}
+}
+
+// Format writes the record as a JSON object
+func (j *JSONFormatter) Format(calldepth int, record *logging.Record, w io.Writer) error {
+	message, fields, ok := recordFields(record)
+	if !ok {
+		message = record.Message()
+	}
+	entry := make(map[string]interface{}, len(fields)+5)
+	for key, value := range fields {
+		entry[key] = jsonFieldValue(value)
+	}
+	entry["timestamp"] = record.Time.Format(time.RFC3339Nano)
+	entry["level"] = record.Level.String()
+	entry["module"] = record.Module
+	entry["message"] = strings.TrimRight(message, "\n")
+	if _, file, line, ok := runtime.Caller(calldepth + 1); ok {
+		entry["file"] = fmt.Sprintf("%s:%d", filepath.Base(file), line)
+	}
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(data)
+	return err
+}
+
+// jsonFieldValue converts values that do not marshal meaningfully, ie errors, to strings
+func jsonFieldValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case error:
+		return v.Error()
+	case time.Duration:
+		return v.String()
+	}
+	if _, err := json.Marshal(value); err != nil {
+		return fmt.Sprint(value)
+	}
+	return value
+}
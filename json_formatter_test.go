@@ -0,0 +1,119 @@
+// Copyright 2020 Daniel Akiva
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goserv
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/emicklei/go-restful"
+	"github.com/op/go-logging"
+	"github.com/stretchr/testify/assert"
+)
+
+func newBufferedLogger(module string, formatter logging.Formatter) (*logging.Logger, *bytes.Buffer) {
+	buffer := &bytes.Buffer{}
+	backend := logging.AddModuleLevel(logging.NewBackendFormatter(logging.NewLogBackend(buffer, "", 0), formatter))
+	backend.SetLevel(logging.DEBUG, module)
+	logger := logging.MustGetLogger(module)
+	logger.SetBackend(backend)
+	return logger, buffer
+}
+
+func decodeLogLines(t *testing.T, buffer *bytes.Buffer) []map[string]interface{} {
+	entries := []map[string]interface{}{}
+	for _, line := range strings.Split(strings.TrimSpace(buffer.String()), "\n") {
+		entry := map[string]interface{}{}
+		assert.NoError(t, json.Unmarshal([]byte(line), &entry))
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+func TestJSONFormatterFields(t *testing.T) {
+	// given
+	logger, buffer := newBufferedLogger("json_formatter_test", NewJSONFormatter())
+	fieldLogger := NewFieldLogger(logger).WithField("trace_id", "abc").WithFields(Fields{"attempt": 2, "message": "ignored", "cause": errors.New("boom")})
+
+	// when
+	fieldLogger.Warningf("retrying %v", "upload")
+
+	// then
+	entry := decodeLogLines(t, buffer)[0]
+	assert.Equal(t, "retrying upload", entry["message"])
+	assert.Equal(t, "WARNING", entry["level"])
+	assert.Equal(t, "json_formatter_test", entry["module"])
+	assert.Equal(t, "abc", entry["trace_id"])
+	assert.Equal(t, float64(2), entry["attempt"])
+	assert.Equal(t, "boom", entry["cause"])
+	assert.Contains(t, entry["file"], "json_formatter_test.go:")
+	assert.NotEmpty(t, entry["timestamp"])
+}
+
+func TestJSONFormatterPlainMessage(t *testing.T) {
+	// given
+	logger, buffer := newBufferedLogger("json_formatter_plain_test", NewJSONFormatter())
+
+	// when
+	logger.Infof("hello %v", "world")
+
+	// then
+	entry := decodeLogLines(t, buffer)[0]
+	assert.Equal(t, "hello world", entry["message"])
+	assert.Contains(t, entry["file"], "json_formatter_test.go:")
+}
+
+func TestFieldLoggerText(t *testing.T) {
+	// given
+	logger, buffer := newBufferedLogger("field_logger_text_test", logging.MustStringFormatter("%{shortfile} %{message}"))
+
+	// when
+	NewFieldLogger(logger).WithFields(Fields{"status": 200, "path": "/a b"}).Info("done")
+
+	// then
+	assert.Regexp(t, `^json_formatter_test.go:\d+ done path="/a b" status=200\n$`, buffer.String())
+}
+
+func TestRestfulLoggingFilterFields(t *testing.T) {
+	// given
+	logger, buffer := newBufferedLogger("restful_logging_fields_test", NewJSONFormatter())
+	ws := new(restful.WebService)
+	ws.Route(ws.GET("/users").To(func(request *restful.Request, response *restful.Response) {
+		response.WriteHeader(http.StatusNotFound)
+	}))
+	container := restful.NewContainer()
+	container.Filter(NewRestfulLoggingFilter(logger).Filter)
+	container.Add(ws)
+
+	// when
+	container.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/users", nil))
+
+	// then
+	entries := decodeLogLines(t, buffer)
+	assert.Len(t, entries, 2)
+	response := entries[1]
+	assert.Equal(t, "ERROR", response["level"])
+	assert.Equal(t, "GET", response["method"])
+	assert.Equal(t, "/users", response["path"])
+	assert.Equal(t, float64(http.StatusNotFound), response["status"])
+	assert.Contains(t, response, "duration_ms")
+	assert.Contains(t, response, "trace_id")
+	assert.True(t, strings.HasPrefix(response["message"].(string), "[Response GET /users]"))
+}
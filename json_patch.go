@@ -0,0 +1,443 @@
+// Copyright 2020 Daniel Akiva
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goserv
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/emicklei/go-restful"
+)
+
+const (
+	// MIMEMergePatchJSON is the RFC 7386 JSON merge patch media type
+	MIMEMergePatchJSON = "application/merge-patch+json"
+	// MIMEJSONPatch is the RFC 6902 JSON patch media type
+	MIMEJSONPatch = "application/json-patch+json"
+)
+
+// PatchResult reports the members of a resource touched by a patch, so that updates can be limited to the changed columns.
+type PatchResult struct {
+	// Paths holds the JSON pointers of the touched members, in the order they were touched
+	Paths []string
+}
+
+// Fields returns the sorted, distinct top level JSON member names touched by the patch
+func (p *PatchResult) Fields() []string {
+	seen := map[string]bool{}
+	fields := []string{}
+	for _, path := range p.Paths {
+		tokens := parseJSONPointer(path)
+		if len(tokens) == 0 || seen[tokens[0]] {
+			continue
+		}
+		seen[tokens[0]] = true
+		fields = append(fields, tokens[0])
+	}
+	sort.Strings(fields)
+	return fields
+}
+
+// Touched returns true if the patch touched the top level JSON member or any of its nested members
+func (p *PatchResult) Touched(field string) bool {
+	for _, f := range p.Fields() {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *PatchResult) touch(path []string) {
+	tokens := make([]interface{}, len(path))
+	for i, token := range path {
+		tokens[i] = token
+	}
+	p.Paths = append(p.Paths, JSONPointer(tokens...))
+}
+
+// JSONPatchOperation represents a single RFC 6902 JSON patch operation
+type JSONPatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+// ExtractPatch applies the body of a PATCH request to a resource, as a JSON patch if the content type is application/json-patch+json and as a JSON merge patch otherwise.
+// The body is subject to the max body size of the RequestBodyConfig installed by InitializeRequestBodies, if any.
+func ExtractPatch(request *restful.Request, resource RequestBody) (*PatchResult, error) {
+	patch, err := currentRequestBodyConfig().readBody(request.Request)
+	if err != nil {
+		return nil, err
+	}
+	if strings.HasPrefix(strings.ToLower(request.HeaderParameter("Content-Type")), MIMEJSONPatch) {
+		return ApplyJSONPatch(resource, patch)
+	}
+	return ApplyMergePatch(resource, patch)
+}
+
+// ApplyMergePatch applies an RFC 7386 JSON merge patch to a pointer to a resource struct. The patched resource is validated like ExtractRequestBody validates a request body, and is only updated if validation succeeds.
+// Members of the patch that cannot be applied, and patches that are not a JSON object and would replace the whole resource, are reported as an IllegalArgumentError.
+func ApplyMergePatch(resource RequestBody, patch []byte) (*PatchResult, error) {
+	patchDoc, err := decodeJSONDocument(patch)
+	if err != nil {
+		return nil, &IllegalArgumentError{Err: err}
+	}
+	if _, ok := patchDoc.(map[string]interface{}); !ok {
+		return nil, &IllegalArgumentError{Err: errors.New("a merge patch must be a JSON object")}
+	}
+	doc, err := toJSONDocument(resource)
+	if err != nil {
+		return nil, err
+	}
+	result := &PatchResult{}
+	doc = mergePatch(doc, patchDoc, nil, result)
+	if err := fromJSONDocument(doc, resource); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// ApplyJSONPatch applies an RFC 6902 JSON patch to a pointer to a resource struct. The patched resource is validated like ExtractRequestBody validates a request body, and is only updated if validation succeeds.
+// Invalid operations and paths, including operations replacing or removing the whole resource, are reported as an IllegalArgumentError naming the path, and a failed test operation as a 409 conflict.
+func ApplyJSONPatch(resource RequestBody, patch []byte) (*PatchResult, error) {
+	operations := []JSONPatchOperation{}
+	if err := json.Unmarshal(patch, &operations); err != nil {
+		return nil, &IllegalArgumentError{Err: err}
+	}
+	doc, err := toJSONDocument(resource)
+	if err != nil {
+		return nil, err
+	}
+	result := &PatchResult{}
+	for _, operation := range operations {
+		if doc, err = applyOperation(doc, operation, result); err != nil {
+			return nil, err
+		}
+	}
+	if err := fromJSONDocument(doc, resource); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func applyOperation(doc interface{}, operation JSONPatchOperation, result *PatchResult) (interface{}, error) {
+	path := parseJSONPointer(operation.Path)
+	if path == nil {
+		return nil, patchPathError(operation.Path, "path must be a JSON pointer")
+	}
+	if len(path) == 0 && operation.Op != "test" {
+		return nil, patchPathError(operation.Path, fmt.Sprintf("%v operation cannot target the whole resource", operation.Op))
+	}
+	switch operation.Op {
+	case "add", "replace", "test":
+		if len(operation.Value) == 0 {
+			return nil, patchPathError(operation.Path, fmt.Sprintf("%v operation requires a value", operation.Op))
+		}
+		value, err := decodeJSONDocument(operation.Value)
+		if err != nil {
+			return nil, patchPathError(operation.Path, err.Error())
+		}
+		switch operation.Op {
+		case "add":
+			result.touch(path)
+			return addValue(doc, path, value, operation.Path)
+		case "replace":
+			if _, err := getValue(doc, path, operation.Path); err != nil {
+				return nil, err
+			}
+			result.touch(path)
+			return replaceValue(doc, path, value), nil
+		default:
+			actual, err := getValue(doc, path, operation.Path)
+			if err != nil {
+				return nil, err
+			}
+			if !jsonEqual(actual, value) {
+				return nil, NewHTTPError(http.StatusConflict, "patch test failed", fmt.Errorf("value at %v does not match", operation.Path))
+			}
+			return doc, nil
+		}
+	case "remove":
+		result.touch(path)
+		doc, _, err := removeValue(doc, path, operation.Path)
+		return doc, err
+	case "move", "copy":
+		from := parseJSONPointer(operation.From)
+		if from == nil {
+			return nil, patchPathError(operation.From, "from must be a JSON pointer")
+		}
+		value, err := getValue(doc, from, operation.From)
+		if err != nil {
+			return nil, err
+		}
+		if operation.Op == "move" {
+			if strings.HasPrefix(operation.Path+"/", operation.From+"/") && operation.Path != operation.From {
+				return nil, patchPathError(operation.Path, "cannot move a value into one of its children")
+			}
+			result.touch(from)
+			if doc, _, err = removeValue(doc, from, operation.From); err != nil {
+				return nil, err
+			}
+		} else if value, err = deepCopyJSON(value); err != nil {
+			return nil, err
+		}
+		result.touch(path)
+		return addValue(doc, path, value, operation.Path)
+	}
+	return nil, patchPathError(operation.Path, fmt.Sprintf("unsupported operation %v", operation.Op))
+}
+
+// mergePatch merges a patch into a target as defined by RFC 7386, recording the paths of the replaced and removed members
+func mergePatch(target interface{}, patch interface{}, path []string, result *PatchResult) interface{} {
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		result.touch(path)
+		return patch
+	}
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = map[string]interface{}{}
+	}
+	keys := make([]string, 0, len(patchObject))
+	for key := range patchObject {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		memberPath := append(append([]string{}, path...), key)
+		if patchObject[key] == nil {
+			if _, exists := targetObject[key]; exists {
+				result.touch(memberPath)
+				delete(targetObject, key)
+			}
+			continue
+		}
+		targetObject[key] = mergePatch(targetObject[key], patchObject[key], memberPath, result)
+	}
+	return targetObject
+}
+
+func getValue(doc interface{}, path []string, pointer string) (interface{}, error) {
+	current := doc
+	for _, token := range path {
+		switch container := current.(type) {
+		case map[string]interface{}:
+			value, ok := container[token]
+			if !ok {
+				return nil, patchPathError(pointer, "path does not exist")
+			}
+			current = value
+		case []interface{}:
+			index, err := arrayIndex(token, len(container)-1)
+			if err != nil {
+				return nil, patchPathError(pointer, err.Error())
+			}
+			current = container[index]
+		default:
+			return nil, patchPathError(pointer, "path does not exist")
+		}
+	}
+	return current, nil
+}
+
+func addValue(doc interface{}, path []string, value interface{}, pointer string) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	parent, err := getValue(doc, path[:len(path)-1], pointer)
+	if err != nil {
+		return nil, err
+	}
+	token := path[len(path)-1]
+	switch container := parent.(type) {
+	case map[string]interface{}:
+		container[token] = value
+		return doc, nil
+	case []interface{}:
+		index := len(container)
+		if token != "-" {
+			if index, err = arrayIndex(token, len(container)); err != nil {
+				return nil, patchPathError(pointer, err.Error())
+			}
+		}
+		updated := append(container[:index:index], append([]interface{}{value}, container[index:]...)...)
+		return replaceValue(doc, path[:len(path)-1], updated), nil
+	}
+	return nil, patchPathError(pointer, "parent is not an object or array")
+}
+
+func removeValue(doc interface{}, path []string, pointer string) (interface{}, interface{}, error) {
+	if len(path) == 0 {
+		return nil, doc, nil
+	}
+	parent, err := getValue(doc, path[:len(path)-1], pointer)
+	if err != nil {
+		return nil, nil, err
+	}
+	token := path[len(path)-1]
+	switch container := parent.(type) {
+	case map[string]interface{}:
+		value, ok := container[token]
+		if !ok {
+			return nil, nil, patchPathError(pointer, "path does not exist")
+		}
+		delete(container, token)
+		return doc, value, nil
+	case []interface{}:
+		index, err := arrayIndex(token, len(container)-1)
+		if err != nil {
+			return nil, nil, patchPathError(pointer, err.Error())
+		}
+		value := container[index]
+		updated := append(container[:index:index], container[index+1:]...)
+		return replaceValue(doc, path[:len(path)-1], updated), value, nil
+	}
+	return nil, nil, patchPathError(pointer, "path does not exist")
+}
+
+// replaceValue replaces the value at an existing path, returning the updated document
+func replaceValue(doc interface{}, path []string, value interface{}) interface{} {
+	if len(path) == 0 {
+		return value
+	}
+	parent, _ := getValue(doc, path[:len(path)-1], "")
+	token := path[len(path)-1]
+	switch container := parent.(type) {
+	case map[string]interface{}:
+		container[token] = value
+	case []interface{}:
+		index, _ := strconv.Atoi(token)
+		container[index] = value
+	}
+	return doc
+}
+
+func arrayIndex(token string, max int) (int, error) {
+	index, err := strconv.Atoi(token)
+	if err != nil || index < 0 || (len(token) > 1 && token[0] == '0') {
+		return 0, fmt.Errorf("invalid array index %v", token)
+	}
+	if index > max {
+		return 0, fmt.Errorf("array index %v out of bounds", token)
+	}
+	return index, nil
+}
+
+// parseJSONPointer splits an RFC 6901 JSON pointer into its unescaped reference tokens, returning nil if the pointer is invalid
+func parseJSONPointer(pointer string) []string {
+	if pointer == "" {
+		return []string{}
+	}
+	if !strings.HasPrefix(pointer, "/") {
+		return nil
+	}
+	tokens := strings.Split(pointer[1:], "/")
+	for i, token := range tokens {
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(token, "~1", "/"), "~0", "~")
+	}
+	return tokens
+}
+
+func patchPathError(pointer string, message string) error {
+	return &IllegalArgumentError{
+		Argument: pointer,
+		Err:      NewValidationBuilder().Add(pathOrRoot(pointer), "patch", message).Error(),
+	}
+}
+
+func decodeJSONDocument(data []byte) (interface{}, error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var doc interface{}
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, err
+	}
+	if _, err := decoder.Token(); err != io.EOF {
+		return nil, errors.New("unexpected data after the JSON document")
+	}
+	return doc, nil
+}
+
+func toJSONDocument(resource interface{}) (interface{}, error) {
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return nil, err
+	}
+	return decodeJSONDocument(data)
+}
+
+// fromJSONDocument decodes a patched document into a copy of the resource, validates it and only then replaces the resource. Fields that are not serialized are carried over from the original resource.
+func fromJSONDocument(doc interface{}, resource RequestBody) error {
+	target := reflect.ValueOf(resource)
+	if target.Kind() != reflect.Ptr || target.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("resource must be a pointer to a struct, got %T", resource)
+	}
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return err
+	}
+	patched := reflect.New(target.Elem().Type())
+	patched.Elem().Set(target.Elem())
+	for i := 0; i < patched.Elem().NumField(); i++ {
+		// serialized fields are decoded from the document, removed members must end up as zero values
+		if _, skip := jsonFieldName(patched.Elem().Type().Field(i)); !skip && patched.Elem().Field(i).CanSet() {
+			patched.Elem().Field(i).Set(reflect.Zero(patched.Elem().Field(i).Type()))
+		}
+	}
+	if err := json.Unmarshal(data, patched.Interface()); err != nil {
+		return decodeError(err)
+	}
+	patchedBody := patched.Interface().(RequestBody)
+	if err := ValidateStruct(patchedBody); err != nil {
+		return err
+	}
+	if err := patchedBody.Validate(); err != nil {
+		return err
+	}
+	target.Elem().Set(patched.Elem())
+	return nil
+}
+
+func deepCopyJSON(value interface{}) (interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	return decodeJSONDocument(data)
+}
+
+// jsonEqual compares two decoded JSON values, treating numbers of equal value as equal
+func jsonEqual(a interface{}, b interface{}) bool {
+	var normalizedA, normalizedB interface{}
+	dataA, errA := json.Marshal(a)
+	dataB, errB := json.Marshal(b)
+	if errA != nil || errB != nil {
+		return false
+	}
+	if json.Unmarshal(dataA, &normalizedA) != nil || json.Unmarshal(dataB, &normalizedB) != nil {
+		return false
+	}
+	return reflect.DeepEqual(normalizedA, normalizedB)
+}
@@ -0,0 +1,175 @@
+// Copyright 2020 Daniel Akiva
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goserv
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/emicklei/go-restful"
+	"github.com/stretchr/testify/assert"
+)
+
+type testPatchResource struct {
+	Name     string            `json:"name" validate:"required,max=20"`
+	Email    string            `json:"email,omitempty"`
+	Tags     []string          `json:"tags"`
+	Labels   map[string]string `json:"labels,omitempty"`
+	Internal string            `json:"-"`
+}
+
+func (t *testPatchResource) Validate() error { return nil }
+
+func newTestPatchResource() *testPatchResource {
+	return &testPatchResource{Name: "bob", Email: "bob@example.com", Tags: []string{"a", "b"}, Internal: "secret"}
+}
+
+func TestApplyMergePatch(t *testing.T) {
+	// given
+	resource := newTestPatchResource()
+
+	// when
+	result, err := ApplyMergePatch(resource, []byte(`{"name":"alice","email":null,"labels":{"team":"core"}}`))
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", resource.Name)
+	assert.Equal(t, "", resource.Email)
+	assert.Equal(t, map[string]string{"team": "core"}, resource.Labels)
+	assert.Equal(t, []string{"a", "b"}, resource.Tags)
+	assert.Equal(t, "secret", resource.Internal)
+	assert.Equal(t, []string{"email", "labels", "name"}, result.Fields())
+	assert.False(t, result.Touched("tags"))
+}
+
+func TestApplyMergePatchValidates(t *testing.T) {
+	// given
+	resource := newTestPatchResource()
+
+	// when
+	_, err := ApplyMergePatch(resource, []byte(`{"name":null}`))
+
+	// then
+	assert.IsType(t, &ValidationError{}, err)
+	assert.Equal(t, "bob", resource.Name)
+}
+
+func TestApplyJSONPatch(t *testing.T) {
+	// given
+	resource := newTestPatchResource()
+	patch := `[
+		{"op":"test","path":"/name","value":"bob"},
+		{"op":"replace","path":"/name","value":"alice"},
+		{"op":"add","path":"/tags/1","value":"x"},
+		{"op":"remove","path":"/tags/0"},
+		{"op":"copy","from":"/name","path":"/email"}
+	]`
+
+	// when
+	result, err := ApplyJSONPatch(resource, []byte(patch))
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, "alice", resource.Name)
+	assert.Equal(t, "alice", resource.Email)
+	assert.Equal(t, []string{"x", "b"}, resource.Tags)
+	assert.Equal(t, []string{"/name", "/tags/1", "/tags/0", "/email"}, result.Paths)
+	assert.Equal(t, []string{"email", "name", "tags"}, result.Fields())
+}
+
+func TestApplyJSONPatchInvalidPath(t *testing.T) {
+	// given
+	resource := newTestPatchResource()
+
+	// when
+	_, err := ApplyJSONPatch(resource, []byte(`[{"op":"replace","path":"/tags/5","value":"x"}]`))
+
+	// then
+	assert.IsType(t, &IllegalArgumentError{}, err)
+	assert.Equal(t, "/tags/5", err.(*IllegalArgumentError).Argument)
+}
+
+func TestApplyJSONPatchFailedTest(t *testing.T) {
+	// given
+	resource := newTestPatchResource()
+
+	// when
+	_, err := ApplyJSONPatch(resource, []byte(`[{"op":"test","path":"/name","value":"alice"},{"op":"remove","path":"/email"}]`))
+
+	// then
+	assert.Equal(t, http.StatusConflict, err.(HTTPError).StatusCode())
+	assert.Equal(t, "bob@example.com", resource.Email)
+}
+
+func TestExtractJSONPatch(t *testing.T) {
+	// given
+	httpReq := httptest.NewRequest(http.MethodPatch, "/resources/1", strings.NewReader(`[{"op":"add","path":"/labels","value":{"team":"core"}}]`))
+	httpReq.Header.Set("Content-Type", MIMEJSONPatch)
+	resource := newTestPatchResource()
+
+	// when
+	result, err := ExtractPatch(restful.NewRequest(httpReq), resource)
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, "core", resource.Labels["team"])
+	assert.True(t, result.Touched("labels"))
+}
+
+func TestApplyMergePatchRejectsNonObject(t *testing.T) {
+	for _, patch := range []string{`null`, `["name"]`, `{"name":"alice"} {}`} {
+		// given
+		resource := newTestPatchResource()
+
+		// when
+		_, err := ApplyMergePatch(resource, []byte(patch))
+
+		// then
+		assert.IsType(t, &IllegalArgumentError{}, err)
+		assert.Equal(t, newTestPatchResource(), resource)
+	}
+}
+
+func TestApplyJSONPatchRejectsWholeResource(t *testing.T) {
+	for _, patch := range []string{`[{"op":"remove","path":""}]`, `[{"op":"replace","path":"","value":{"name":"alice"}}]`} {
+		// given
+		resource := newTestPatchResource()
+
+		// when
+		_, err := ApplyJSONPatch(resource, []byte(patch))
+
+		// then
+		assert.IsType(t, &IllegalArgumentError{}, err)
+		assert.Equal(t, newTestPatchResource(), resource)
+	}
+}
+
+func TestExtractPatchTooLarge(t *testing.T) {
+	// given
+	(&RequestBodyConfig{MaxBodySize: 16}).InitializeRequestBodies()
+	defer (&RequestBodyConfig{}).InitializeRequestBodies()
+	httpReq := httptest.NewRequest(http.MethodPatch, "/resources/1", strings.NewReader(`{"name":"alice","email":"alice@example.com"}`))
+	httpReq.Header.Set("Content-Type", MIMEMergePatchJSON)
+	resource := newTestPatchResource()
+
+	// when
+	_, err := ExtractPatch(restful.NewRequest(httpReq), resource)
+
+	// then
+	assert.IsType(t, &PayloadTooLargeError{}, err)
+	assert.Equal(t, "bob", resource.Name)
+}
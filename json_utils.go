@@ -17,7 +17,11 @@ package goserv
 import (
 	"encoding/json"
 	"fmt"
+	"math"
 	"reflect"
+	"strconv"
+	"strings"
+	"time"
 )
 
 // ConvertToInteger converts a raw unmarshaled value to a float64. When unmarshaling to an interface, the unmarshaler may unmarshal a number to a json.Number or a float64. The float is then floored by this function and returned. This conversion function encapsulates this behavior.
@@ -46,3 +50,209 @@ func ConvertToFloat(unmarshaledVal interface{}) (float64, error) {
 	}
 	return 0, fmt.Errorf("conversion called with an interface with type %v", reflect.TypeOf(unmarshaledVal))
 }
+
+// LookupJSONPath returns the value at a path within an unmarshaled JSON object. Paths are dot separated member names with bracketed array indexes, ie a.b[2].c
+// Returns an IllegalArgumentError naming the path if the path is invalid or the value does not exist.
+func LookupJSONPath(obj map[string]interface{}, path string) (interface{}, error) {
+	tokens, err := parseJSONPath(path)
+	if err != nil {
+		return nil, &IllegalArgumentError{Argument: path, Err: err}
+	}
+	var current interface{} = obj
+	traversed := ""
+	for _, token := range tokens {
+		if index, ok := token.(int); ok {
+			array, isArray := current.([]interface{})
+			if !isArray {
+				return nil, &IllegalArgumentError{Argument: path, Err: fmt.Errorf("%v: expected array but got %v", pathOrRoot(traversed), jsonTypeName(current))}
+			}
+			traversed += fmt.Sprintf("[%v]", index)
+			if index >= len(array) {
+				return nil, &IllegalArgumentError{Argument: path, Err: fmt.Errorf("%v: index out of bounds, array has %v elements", traversed, len(array))}
+			}
+			current = array[index]
+			continue
+		}
+		object, isObject := current.(map[string]interface{})
+		if !isObject {
+			return nil, &IllegalArgumentError{Argument: path, Err: fmt.Errorf("%v: expected object but got %v", pathOrRoot(traversed), jsonTypeName(current))}
+		}
+		if traversed != "" {
+			traversed += "."
+		}
+		traversed += token.(string)
+		value, exists := object[token.(string)]
+		if !exists {
+			return nil, &IllegalArgumentError{Argument: path, Err: fmt.Errorf("%v: value is missing", traversed)}
+		}
+		current = value
+	}
+	return current, nil
+}
+
+// GetString returns the string at a path within an unmarshaled JSON object
+func GetString(obj map[string]interface{}, path string) (string, error) {
+	value, err := LookupJSONPath(obj, path)
+	if err != nil {
+		return "", err
+	}
+	s, ok := value.(string)
+	if !ok {
+		return "", jsonTypeError(path, "string", value)
+	}
+	return s, nil
+}
+
+// GetBool returns the boolean at a path within an unmarshaled JSON object
+func GetBool(obj map[string]interface{}, path string) (bool, error) {
+	value, err := LookupJSONPath(obj, path)
+	if err != nil {
+		return false, err
+	}
+	b, ok := value.(bool)
+	if !ok {
+		return false, jsonTypeError(path, "boolean", value)
+	}
+	return b, nil
+}
+
+// GetInt returns the integer at a path within an unmarshaled JSON object. Unlike ConvertToInteger, numbers with a fraction or outside the range of an int are rejected.
+func GetInt(obj map[string]interface{}, path string) (int, error) {
+	value, err := LookupJSONPath(obj, path)
+	if err != nil {
+		return 0, err
+	}
+	var i int64
+	switch v := value.(type) {
+	case int:
+		return v, nil
+	case int64:
+		i = v
+	case json.Number:
+		if i, err = strconv.ParseInt(v.String(), 10, 64); err != nil {
+			f, floatErr := v.Float64()
+			if floatErr != nil {
+				return 0, &IllegalArgumentError{Argument: path, Err: fmt.Errorf("%v is not a valid number", v)}
+			}
+			if i, err = floatToInt64(path, f); err != nil {
+				return 0, err
+			}
+		}
+	case float64:
+		if i, err = floatToInt64(path, v); err != nil {
+			return 0, err
+		}
+	default:
+		return 0, jsonTypeError(path, "integer", value)
+	}
+	if int64(int(i)) != i {
+		return 0, &IllegalArgumentError{Argument: path, Err: fmt.Errorf("%v overflows int", i)}
+	}
+	return int(i), nil
+}
+
+// GetTime returns the RFC 3339 time at a path within an unmarshaled JSON object
+func GetTime(obj map[string]interface{}, path string) (time.Time, error) {
+	s, err := GetString(obj, path)
+	if err != nil {
+		return time.Time{}, err
+	}
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		return time.Time{}, &IllegalArgumentError{Argument: path, Err: fmt.Errorf("expected an RFC 3339 time but got %v", s)}
+	}
+	return t, nil
+}
+
+// GetArray returns the array at a path within an unmarshaled JSON object
+func GetArray(obj map[string]interface{}, path string) ([]interface{}, error) {
+	value, err := LookupJSONPath(obj, path)
+	if err != nil {
+		return nil, err
+	}
+	array, ok := value.([]interface{})
+	if !ok {
+		return nil, jsonTypeError(path, "array", value)
+	}
+	return array, nil
+}
+
+// GetObject returns the object at a path within an unmarshaled JSON object
+func GetObject(obj map[string]interface{}, path string) (map[string]interface{}, error) {
+	value, err := LookupJSONPath(obj, path)
+	if err != nil {
+		return nil, err
+	}
+	object, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, jsonTypeError(path, "object", value)
+	}
+	return object, nil
+}
+
+func floatToInt64(path string, f float64) (int64, error) {
+	if f != math.Trunc(f) {
+		return 0, &IllegalArgumentError{Argument: path, Err: fmt.Errorf("%v is not an integer", f)}
+	}
+	if f < math.MinInt64 || f >= math.MaxInt64 {
+		return 0, &IllegalArgumentError{Argument: path, Err: fmt.Errorf("%v overflows int", f)}
+	}
+	return int64(f), nil
+}
+
+func jsonTypeError(path string, expected string, value interface{}) error {
+	return &IllegalArgumentError{Argument: path, Err: fmt.Errorf("expected %v but got %v", expected, jsonTypeName(value))}
+}
+
+// jsonTypeName returns the JSON type of an unmarshaled value
+func jsonTypeName(value interface{}) string {
+	switch value.(type) {
+	case nil:
+		return "null"
+	case string:
+		return "string"
+	case bool:
+		return "boolean"
+	case float64, float32, int, int64, json.Number:
+		return "number"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	}
+	return reflect.TypeOf(value).String()
+}
+
+// parseJSONPath splits a path such as a.b[2].c into member name and array index tokens
+func parseJSONPath(path string) ([]interface{}, error) {
+	if path == "" {
+		return nil, fmt.Errorf("path must not be empty")
+	}
+	tokens := []interface{}{}
+	for _, segment := range strings.Split(path, ".") {
+		name := segment
+		indexes := ""
+		if idx := strings.Index(segment, "["); idx >= 0 {
+			name, indexes = segment[:idx], segment[idx:]
+		}
+		if name == "" && (indexes == "" || len(tokens) > 0) {
+			return nil, fmt.Errorf("invalid path %v: empty member name", path)
+		}
+		if name != "" {
+			tokens = append(tokens, name)
+		}
+		for indexes != "" {
+			end := strings.Index(indexes, "]")
+			if indexes[0] != '[' || end < 0 {
+				return nil, fmt.Errorf("invalid path %v: malformed array index", path)
+			}
+			index, err := strconv.Atoi(indexes[1:end])
+			if err != nil || index < 0 {
+				return nil, fmt.Errorf("invalid path %v: invalid array index %v", path, indexes[1:end])
+			}
+			tokens = append(tokens, index)
+			indexes = indexes[end+1:]
+		}
+	}
+	return tokens, nil
+}
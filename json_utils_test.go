@@ -141,3 +141,88 @@ func TestConvertNumberValToInteger(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, expected, f)
 }
+
+func newTestPayload() map[string]interface{} {
+	payload := map[string]interface{}{}
+	_ = json.Unmarshal([]byte(`{
+		"event": "push",
+		"active": true,
+		"count": 3,
+		"ratio": 3.7,
+		"big": 1e40,
+		"created_at": "2020-05-06T07:08:09Z",
+		"repo": {"commits": [{"id": "a"}, {"id": "b"}, {"id": "c", "files": ["x.go"]}]}
+	}`), &payload)
+	return payload
+}
+
+func TestGetTypedValues(t *testing.T) {
+	// given
+	payload := newTestPayload()
+
+	// when
+	event, eventErr := GetString(payload, "event")
+	active, activeErr := GetBool(payload, "active")
+	count, countErr := GetInt(payload, "count")
+	created, createdErr := GetTime(payload, "created_at")
+	commits, commitsErr := GetArray(payload, "repo.commits")
+	commit, commitErr := GetObject(payload, "repo.commits[2]")
+	file, fileErr := GetString(payload, "repo.commits[2].files[0]")
+
+	// then
+	assert.NoError(t, eventErr)
+	assert.Equal(t, "push", event)
+	assert.NoError(t, activeErr)
+	assert.True(t, active)
+	assert.NoError(t, countErr)
+	assert.Equal(t, 3, count)
+	assert.NoError(t, createdErr)
+	assert.Equal(t, 2020, created.Year())
+	assert.NoError(t, commitsErr)
+	assert.Len(t, commits, 3)
+	assert.NoError(t, commitErr)
+	assert.Equal(t, "c", commit["id"])
+	assert.NoError(t, fileErr)
+	assert.Equal(t, "x.go", file)
+}
+
+func TestGetIntRejectsFractionAndOverflow(t *testing.T) {
+	// given
+	payload := newTestPayload()
+
+	// when
+	_, fractionErr := GetInt(payload, "ratio")
+	_, overflowErr := GetInt(payload, "big")
+
+	// then
+	assert.EqualError(t, fractionErr, "illegal argument error: ratio :3.7 is not an integer")
+	assert.Error(t, overflowErr)
+}
+
+func TestGetWrongType(t *testing.T) {
+	// given
+	payload := newTestPayload()
+
+	// when
+	_, err := GetString(payload, "repo.commits")
+
+	// then
+	assert.IsType(t, &IllegalArgumentError{}, err)
+	assert.Equal(t, "repo.commits", err.(*IllegalArgumentError).Argument)
+	assert.Contains(t, err.Error(), "expected string but got array")
+}
+
+func TestLookupJSONPathMissing(t *testing.T) {
+	// given
+	payload := newTestPayload()
+
+	// when
+	_, missingErr := LookupJSONPath(payload, "repo.branch")
+	_, boundsErr := LookupJSONPath(payload, "repo.commits[5].id")
+	_, syntaxErr := LookupJSONPath(payload, "repo.commits[x]")
+
+	// then
+	assert.Contains(t, missingErr.Error(), "repo.branch: value is missing")
+	assert.Contains(t, boundsErr.Error(), "repo.commits[5]: index out of bounds")
+	assert.Contains(t, syntaxErr.Error(), "invalid array index x")
+}
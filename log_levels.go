@@ -0,0 +1,383 @@
+// Copyright 2020 Daniel Akiva
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goserv
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/emicklei/go-restful"
+	"github.com/op/go-logging"
+)
+
+// RootLogModule is the module name used by the logging WebService to address the default level, which applies to modules without a level of their own
+const RootLogModule = "root"
+
+var (
+	logLevelsLock sync.RWMutex
+	logLevels     *moduleLevels
+)
+
+// LogLevelResource represents the level of a logging module, exposed as an API.
+type LogLevelResource struct {
+	Module          string     `json:"module" description:"The name of the module, root for the default level."`
+	Level           string     `json:"level" description:"The level currently in effect."`
+	ConfiguredLevel string     `json:"configured_level,omitempty" description:"The level the module reverts to once a temporary level expires, empty if the module reverts to the default level."`
+	ExpiresAt       *time.Time `json:"expires_at,omitempty" description:"Timestamp in UTC of when a temporary level reverts."`
+}
+
+// LogLevelRequest represents a request to change the level of a logging module.
+type LogLevelRequest struct {
+	Level string `json:"level" description:"One of CRITICAL, ERROR, WARNING, NOTICE, INFO, DEBUG."`
+	TTL   int    `json:"ttl" description:"The number of seconds after which the level reverts, 0 changes the level permanently."`
+}
+
+// Validate ensures the request holds a valid level and ttl
+func (l *LogLevelRequest) Validate() error {
+	if _, err := logging.LogLevel(l.Level); err != nil {
+		return &IllegalArgumentError{Argument: "level", Err: fmt.Errorf("invalid log level %v", l.Level)}
+	}
+	if l.TTL < 0 {
+		return &IllegalArgumentError{Argument: "ttl", Err: errors.New("ttl must not be negative")}
+	}
+	return nil
+}
+
+// SetLogLevel changes the level of a module at runtime, the empty module being the default level. If ttl is positive the module reverts to its configured level once the ttl elapses, otherwise the change is permanent.
+// Returns an error if logging has not been initialized.
+func SetLogLevel(module string, level logging.Level, ttl time.Duration) error {
+	levels := currentLogLevels()
+	if levels == nil {
+		return errors.New("logging has not been initialized")
+	}
+	if ttl > 0 {
+		levels.setTemporaryLevel(level, module, ttl)
+	} else {
+		levels.SetLevel(level, module)
+	}
+	return nil
+}
+
+// ResetLogLevel reverts a module to its configured level, cancelling a temporary level. Returns an error if logging has not been initialized.
+func ResetLogLevel(module string) error {
+	levels := currentLogLevels()
+	if levels == nil {
+		return errors.New("logging has not been initialized")
+	}
+	levels.reset(module)
+	return nil
+}
+
+// LogLevels returns the levels of the default module and of all modules with a level of their own, sorted by module
+func LogLevels() []LogLevelResource {
+	levels := currentLogLevels()
+	if levels == nil {
+		return []LogLevelResource{}
+	}
+	return levels.resources()
+}
+
+// LoggingService exposes the levels of logging modules and the counters of asynchronous backends as a go-restful WebService, so that levels can be changed without restarting the service.
+// The WebService should be protected, ie by a token auth filter, as it is intended for administrators.
+type LoggingService struct{}
+
+// NewLoggingService initializes a new instance
+func NewLoggingService() *LoggingService {
+	return &LoggingService{}
+}
+
+// WebService returns a WebService exposing GET /logging/levels, GET, PUT and DELETE /logging/levels/{module}, and GET /logging/stats. The root module addresses the default level.
+func (l *LoggingService) WebService() *restful.WebService {
+	ws := new(restful.WebService)
+	ws.Path("/logging").Consumes(restful.MIME_JSON).Produces(restful.MIME_JSON)
+	module := ws.PathParameter("module", "The name of the module, root for the default level.").DataType("string")
+	ws.Route(ws.GET("/levels").To(l.handleList).
+		Doc("Lists the levels of all modules").
+		Writes([]LogLevelResource{}).
+		Returns(http.StatusOK, "OK", []LogLevelResource{}))
+	ws.Route(ws.GET("/levels/{module}").To(l.handleGet).
+		Doc("Returns the level of a module").
+		Param(module).
+		Writes(LogLevelResource{}).
+		Returns(http.StatusOK, "OK", LogLevelResource{}))
+	ws.Route(ws.PUT("/levels/{module}").To(l.handleSet).
+		Doc("Changes the level of a module, optionally reverting after a ttl").
+		Param(module).
+		Reads(LogLevelRequest{}).
+		Writes(LogLevelResource{}).
+		Returns(http.StatusOK, "OK", LogLevelResource{}).
+		Returns(http.StatusBadRequest, "Bad Request", nil))
+	ws.Route(ws.DELETE("/levels/{module}").To(l.handleReset).
+		Doc("Reverts a module to its configured level").
+		Param(module).
+		Writes(LogLevelResource{}).
+		Returns(http.StatusOK, "OK", LogLevelResource{}))
+	ws.Route(ws.GET("/stats").To(l.handleStats).
+		Doc("Lists the queue and drop counters of the asynchronous backends").
+		Writes([]AsyncBackendStats{}).
+		Returns(http.StatusOK, "OK", []AsyncBackendStats{}))
+	return ws
+}
+
+func (l *LoggingService) handleList(request *restful.Request, response *restful.Response) {
+	response.WriteHeaderAndJson(http.StatusOK, LogLevels(), restful.MIME_JSON)
+}
+
+func (l *LoggingService) handleStats(request *restful.Request, response *restful.Response) {
+	response.WriteHeaderAndJson(http.StatusOK, LoggingStats(), restful.MIME_JSON)
+}
+
+func (l *LoggingService) handleGet(request *restful.Request, response *restful.Response) {
+	levels := currentLogLevels()
+	if levels == nil {
+		WriteRequestError(request, response, &ServiceUnavailableError{Err: errors.New("logging has not been initialized")})
+		return
+	}
+	response.WriteHeaderAndJson(http.StatusOK, levels.resource(pathModule(request)), restful.MIME_JSON)
+}
+
+func (l *LoggingService) handleSet(request *restful.Request, response *restful.Response) {
+	body := &LogLevelRequest{}
+	if err := ExtractRequestBody(request, body); err != nil {
+		WriteRequestError(request, response, err)
+		return
+	}
+	module := pathModule(request)
+	level, _ := logging.LogLevel(body.Level)
+	if err := SetLogLevel(module, level, time.Duration(body.TTL)*time.Second); err != nil {
+		WriteRequestError(request, response, &ServiceUnavailableError{Err: err})
+		return
+	}
+	response.WriteHeaderAndJson(http.StatusOK, currentLogLevels().resource(module), restful.MIME_JSON)
+}
+
+func (l *LoggingService) handleReset(request *restful.Request, response *restful.Response) {
+	module := pathModule(request)
+	if err := ResetLogLevel(module); err != nil {
+		WriteRequestError(request, response, &ServiceUnavailableError{Err: err})
+		return
+	}
+	response.WriteHeaderAndJson(http.StatusOK, currentLogLevels().resource(module), restful.MIME_JSON)
+}
+
+// pathModule returns the module addressed by a request, mapping the root module to the empty module
+func pathModule(request *restful.Request) string {
+	module := request.PathParameter("module")
+	if module == RootLogModule {
+		return ""
+	}
+	return module
+}
+
+func setLogLevels(levels *moduleLevels) {
+	logLevelsLock.Lock()
+	defer logLevelsLock.Unlock()
+	if logLevels != nil {
+		logLevels.stop()
+	}
+	logLevels = levels
+}
+
+func currentLogLevels() *moduleLevels {
+	logLevelsLock.RLock()
+	defer logLevelsLock.RUnlock()
+	return logLevels
+}
+
+// moduleLevels is a logging.LeveledBackend holding per module levels that can be changed while logging, as the levels of op/go-logging backends are not safe for concurrent use.
+// Levels are either configured, or temporary in which case they revert to the configured level once they expire.
+type moduleLevels struct {
+	backend logging.Backend
+	// verbose is the most verbose level of the backends with a level of their own, which receive records of modules without a level of their own down to that level
+	verbose    logging.Level
+	lock       sync.RWMutex
+	configured map[string]logging.Level
+	levels     map[string]logging.Level
+	expires    map[string]time.Time
+	timers     map[string]*time.Timer
+}
+
+// newModuleLevels wraps a backend, the backend must not filter records by level itself
+func newModuleLevels(backend logging.Backend) *moduleLevels {
+	return &moduleLevels{
+		backend:    backend,
+		configured: map[string]logging.Level{},
+		levels:     map[string]logging.Level{},
+		expires:    map[string]time.Time{},
+		timers:     map[string]*time.Timer{},
+	}
+}
+
+// GetLevel returns the level of a module, falling back to the default level and then DEBUG
+func (m *moduleLevels) GetLevel(module string) logging.Level {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return m.getLevel(module)
+}
+
+// SetLevel permanently sets the level of a module, cancelling a temporary level
+func (m *moduleLevels) SetLevel(level logging.Level, module string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.cancel(module)
+	m.configured[module] = level
+	m.levels[module] = level
+}
+
+// IsEnabledFor returns true if a module logs at a level to any backend
+func (m *moduleLevels) IsEnabledFor(level logging.Level, module string) bool {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	if moduleLevel, ok := m.levels[module]; ok && module != "" {
+		return level <= moduleLevel
+	}
+	return level <= m.getLevel(module) || level <= m.verbose
+}
+
+// isEnabledForBackend returns true if a backend logs a record of a module at a level. Modules without a level of their own log at the level of the backend, if not nil, rather than at the default level.
+func (m *moduleLevels) isEnabledForBackend(level logging.Level, module string, backendLevel *logging.Level) bool {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	if moduleLevel, ok := m.levels[module]; ok && module != "" {
+		return level <= moduleLevel && (backendLevel == nil || level <= *backendLevel)
+	}
+	if backendLevel != nil {
+		return level <= *backendLevel
+	}
+	return level <= m.getLevel(module)
+}
+
+// Log passes a record to the backend if the module of the record logs at the level
+func (m *moduleLevels) Log(level logging.Level, calldepth int, record *logging.Record) error {
+	if !m.IsEnabledFor(level, record.Module) {
+		return nil
+	}
+	return m.backend.Log(level, calldepth+1, record)
+}
+
+// backendLevelFilter passes records on to a backend of the logging configuration according to the module levels and the level of the backend, if set
+type backendLevelFilter struct {
+	backend logging.Backend
+	level   *logging.Level
+	levels  *moduleLevels
+}
+
+// Log passes a record to the backend if the backend logs the module of the record at the level
+func (b *backendLevelFilter) Log(level logging.Level, calldepth int, record *logging.Record) error {
+	if !b.levels.isEnabledForBackend(level, record.Module, b.level) {
+		return nil
+	}
+	return b.backend.Log(level, calldepth+1, record)
+}
+
+func (m *moduleLevels) getLevel(module string) logging.Level {
+	if level, ok := m.levels[module]; ok {
+		return level
+	}
+	if level, ok := m.levels[""]; ok {
+		return level
+	}
+	return logging.DEBUG
+}
+
+func (m *moduleLevels) setTemporaryLevel(level logging.Level, module string, ttl time.Duration) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.cancel(module)
+	m.levels[module] = level
+	m.expires[module] = time.Now().Add(ttl)
+	var timer *time.Timer
+	timer = time.AfterFunc(ttl, func() {
+		m.lock.Lock()
+		defer m.lock.Unlock()
+		// a later change replaces the timer, in which case this one no longer applies
+		if m.timers[module] == timer {
+			m.revert(module)
+		}
+	})
+	m.timers[module] = timer
+}
+
+func (m *moduleLevels) reset(module string) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.revert(module)
+}
+
+// revert restores the configured level of a module, or removes the level if none was configured
+func (m *moduleLevels) revert(module string) {
+	m.cancel(module)
+	if level, ok := m.configured[module]; ok {
+		m.levels[module] = level
+	} else {
+		delete(m.levels, module)
+	}
+}
+
+func (m *moduleLevels) cancel(module string) {
+	if timer, ok := m.timers[module]; ok {
+		timer.Stop()
+		delete(m.timers, module)
+	}
+	delete(m.expires, module)
+}
+
+// stop cancels all pending reverts, used when logging is initialized again
+func (m *moduleLevels) stop() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	for module := range m.timers {
+		m.cancel(module)
+	}
+}
+
+func (m *moduleLevels) resource(module string) LogLevelResource {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	name := module
+	if name == "" {
+		name = RootLogModule
+	}
+	resource := LogLevelResource{Module: name, Level: m.getLevel(module).String()}
+	if level, ok := m.configured[module]; ok {
+		resource.ConfiguredLevel = level.String()
+	}
+	if expires, ok := m.expires[module]; ok {
+		expires = expires.UTC()
+		resource.ExpiresAt = &expires
+	}
+	return resource
+}
+
+func (m *moduleLevels) resources() []LogLevelResource {
+	m.lock.RLock()
+	modules := []string{""}
+	for module := range m.levels {
+		if module != "" {
+			modules = append(modules, module)
+		}
+	}
+	m.lock.RUnlock()
+	sort.Strings(modules)
+	resources := make([]LogLevelResource, len(modules))
+	for i, module := range modules {
+		resources[i] = m.resource(module)
+	}
+	return resources
+}
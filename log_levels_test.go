@@ -0,0 +1,143 @@
+// Copyright 2020 Daniel Akiva
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goserv
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emicklei/go-restful"
+	"github.com/op/go-logging"
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestModuleLevels() *moduleLevels {
+	levels := newModuleLevels(logging.AddModuleLevel(logging.NewMemoryBackend(10)))
+	levels.SetLevel(logging.INFO, "")
+	levels.SetLevel(logging.WARNING, "http")
+	return levels
+}
+
+func TestModuleLevels(t *testing.T) {
+	// given
+	levels := newTestModuleLevels()
+
+	// when
+	httpInfo := levels.IsEnabledFor(logging.INFO, "http")
+	dbInfo := levels.IsEnabledFor(logging.INFO, "db")
+	dbDebug := levels.IsEnabledFor(logging.DEBUG, "db")
+
+	// then
+	assert.False(t, httpInfo)
+	assert.True(t, dbInfo)
+	assert.False(t, dbDebug)
+}
+
+func TestTemporaryLevelReverts(t *testing.T) {
+	// given
+	levels := newTestModuleLevels()
+
+	// when
+	levels.setTemporaryLevel(logging.DEBUG, "http", 20*time.Millisecond)
+	levels.setTemporaryLevel(logging.DEBUG, "db", 20*time.Millisecond)
+	during := []logging.Level{levels.GetLevel("http"), levels.GetLevel("db")}
+	time.Sleep(100 * time.Millisecond)
+
+	// then
+	assert.Equal(t, []logging.Level{logging.DEBUG, logging.DEBUG}, during)
+	assert.Equal(t, logging.WARNING, levels.GetLevel("http"))
+	assert.Equal(t, logging.INFO, levels.GetLevel("db"))
+	assert.Len(t, levels.resources(), 2)
+}
+
+func TestPermanentLevelCancelsRevert(t *testing.T) {
+	// given
+	levels := newTestModuleLevels()
+	levels.setTemporaryLevel(logging.DEBUG, "http", 20*time.Millisecond)
+
+	// when
+	levels.SetLevel(logging.ERROR, "http")
+	time.Sleep(100 * time.Millisecond)
+
+	// then
+	assert.Equal(t, logging.ERROR, levels.GetLevel("http"))
+}
+
+func TestLoggingServiceSetLevel(t *testing.T) {
+	// given
+	setLogLevels(newTestModuleLevels())
+	defer setLogLevels(nil)
+	container := restful.NewContainer()
+	container.Add(NewLoggingService().WebService())
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPut, "/logging/levels/http", strings.NewReader(`{"level": "DEBUG", "ttl": 60}`))
+	req.Header.Set("Content-Type", restful.MIME_JSON)
+
+	// when
+	container.ServeHTTP(recorder, req)
+
+	// then
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	resource := LogLevelResource{}
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resource))
+	assert.Equal(t, "http", resource.Module)
+	assert.Equal(t, "DEBUG", resource.Level)
+	assert.Equal(t, "WARNING", resource.ConfiguredLevel)
+	assert.NotNil(t, resource.ExpiresAt)
+}
+
+func TestLoggingServiceInvalidLevel(t *testing.T) {
+	// given
+	setLogLevels(newTestModuleLevels())
+	defer setLogLevels(nil)
+	container := restful.NewContainer()
+	container.Add(NewLoggingService().WebService())
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodPut, "/logging/levels/root", strings.NewReader(`{"level": "VERBOSE"}`))
+	req.Header.Set("Content-Type", restful.MIME_JSON)
+
+	// when
+	container.ServeHTTP(recorder, req)
+
+	// then
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	assert.Equal(t, logging.INFO, currentLogLevels().GetLevel(""))
+}
+
+func TestLoggingServiceResetLevel(t *testing.T) {
+	// given
+	setLogLevels(newTestModuleLevels())
+	defer setLogLevels(nil)
+	SetLogLevel("db", logging.DEBUG, time.Minute)
+	container := restful.NewContainer()
+	container.Add(NewLoggingService().WebService())
+	recorder := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodDelete, "/logging/levels/db", nil)
+
+	// when
+	container.ServeHTTP(recorder, req)
+
+	// then
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	resource := LogLevelResource{}
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &resource))
+	assert.Equal(t, "INFO", resource.Level)
+	assert.Empty(t, resource.ConfiguredLevel)
+	assert.Nil(t, resource.ExpiresAt)
+}
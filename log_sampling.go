@@ -0,0 +1,194 @@
+// Copyright 2020 Daniel Akiva
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goserv
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/op/go-logging"
+)
+
+const (
+	// DefaultSamplingInterval is the default number of seconds of a sampling window
+	DefaultSamplingInterval = 1
+	// DefaultSamplingReportInterval is the default number of seconds between reports of suppressed messages
+	DefaultSamplingReportInterval = 60
+)
+
+var (
+	logSamplerLock sync.RWMutex
+	logSampler     *sampler
+)
+
+// SamplingConfig represents sampling of noisy log messages. Messages are keyed by module, level and message template, so that messages differing only by their arguments are sampled together.
+// Sampling applies to the RestfulLoggingFilter, error responses, the database wrappers and FieldLoggers. The number of suppressed messages per template is logged every report interval.
+type SamplingConfig struct {
+	// ReportInterval is the number of seconds between reports of suppressed messages, defaulting to 60
+	ReportInterval int            `json:"report_interval"`
+	Rules          []SamplingRule `json:"rules"`
+}
+
+// SamplingRule logs the First messages of a template per Interval seconds, and then 1 in every Thereafter messages. A Thereafter of 0 suppresses all messages beyond the first.
+// The first rule matching the module of a message applies, a rule without a module matches all modules.
+type SamplingRule struct {
+	Module     string `json:"module"`
+	Interval   int    `json:"interval"`
+	First      int    `json:"first"`
+	Thereafter int    `json:"thereafter"`
+}
+
+// Validate ensures the configuration is valid
+func (s *SamplingConfig) Validate() error {
+	if s.ReportInterval < 0 {
+		return errors.New("sampling report interval must not be negative")
+	}
+	for _, rule := range s.Rules {
+		if rule.Interval < 0 || rule.First < 0 || rule.Thereafter < 0 {
+			return fmt.Errorf("sampling rule for module %q must not have a negative interval, first or thereafter", rule.Module)
+		}
+	}
+	return nil
+}
+
+// sampledTemplate holds the counters of a message template within the current sampling window
+type sampledTemplate struct {
+	logger      *logging.Logger
+	level       logging.Level
+	template    string
+	windowStart time.Time
+	count       int
+	suppressed  int
+	lastSeen    time.Time
+}
+
+// sampler decides whether messages are logged according to the sampling rules, and periodically reports suppressed messages
+type sampler struct {
+	rules     []SamplingRule
+	lock      sync.Mutex
+	templates map[string]*sampledTemplate
+	now       func() time.Time
+	stop      chan struct{}
+}
+
+func newSampler(config *SamplingConfig) *sampler {
+	s := &sampler{
+		rules:     config.Rules,
+		templates: map[string]*sampledTemplate{},
+		now:       time.Now,
+		stop:      make(chan struct{}),
+	}
+	reportInterval := time.Duration(config.ReportInterval) * time.Second
+	if reportInterval == 0 {
+		reportInterval = DefaultSamplingReportInterval * time.Second
+	}
+	go func() {
+		ticker := time.NewTicker(reportInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				s.report()
+			case <-s.stop:
+				return
+			}
+		}
+	}()
+	return s
+}
+
+// allow returns true if a message of the template is logged, counting it as suppressed otherwise
+func (s *sampler) allow(logger *logging.Logger, level logging.Level, template string) bool {
+	rule := s.rule(logger.Module)
+	if rule == nil {
+		return true
+	}
+	interval := time.Duration(rule.Interval) * time.Second
+	if interval == 0 {
+		interval = DefaultSamplingInterval * time.Second
+	}
+	key := fmt.Sprintf("%s|%d|%s", logger.Module, level, template)
+	now := s.now()
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	sampled, ok := s.templates[key]
+	if !ok {
+		sampled = &sampledTemplate{level: level, template: template, windowStart: now}
+		s.templates[key] = sampled
+	}
+	sampled.logger = logger
+	sampled.lastSeen = now
+	if now.Sub(sampled.windowStart) >= interval {
+		sampled.windowStart = now
+		sampled.count = 0
+	}
+	sampled.count++
+	if sampled.count <= rule.First || (rule.Thereafter > 0 && (sampled.count-rule.First)%rule.Thereafter == 0) {
+		return true
+	}
+	sampled.suppressed++
+	return false
+}
+
+func (s *sampler) rule(module string) *SamplingRule {
+	for i := range s.rules {
+		if s.rules[i].Module == "" || s.rules[i].Module == module {
+			return &s.rules[i]
+		}
+	}
+	return nil
+}
+
+// report logs the number of suppressed messages per template since the last report, and forgets templates no longer logged
+func (s *sampler) report() {
+	now := s.now()
+	reports := []sampledTemplate{}
+	s.lock.Lock()
+	for key, sampled := range s.templates {
+		if sampled.suppressed > 0 {
+			reports = append(reports, *sampled)
+			sampled.suppressed = 0
+		} else if now.Sub(sampled.lastSeen) > time.Minute {
+			delete(s.templates, key)
+		}
+	}
+	s.lock.Unlock()
+	for _, sampled := range reports {
+		fields := Fields{"sampled_template": sampled.template, "suppressed": sampled.suppressed}
+		logAtLevel(sampled.logger, sampled.level, "[sampling] suppressed %d messages like %q%v", sampled.suppressed, sampled.template, structuredFields(fields))
+	}
+}
+
+// sampled returns true if a message of the template should be logged according to the sampling configured by InitializeLogging
+func sampled(logger *logging.Logger, level logging.Level, template string) bool {
+	logSamplerLock.RLock()
+	s := logSampler
+	logSamplerLock.RUnlock()
+	return s == nil || s.allow(logger, level, template)
+}
+
+// setSampler replaces the sampler, reporting the messages suppressed by the previous one
+func setSampler(s *sampler) {
+	logSamplerLock.Lock()
+	previous := logSampler
+	logSampler = s
+	logSamplerLock.Unlock()
+	if previous != nil {
+		close(previous.stop)
+		previous.report()
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright 2020 Daniel Akiva
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goserv
+
+import (
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/op/go-logging"
+	"github.com/stretchr/testify/assert"
+)
+
+func newSamplingTestLogger(module string) (*logging.Logger, *logging.MemoryBackend) {
+	memory := logging.NewMemoryBackend(100)
+	logger := logging.MustGetLogger(module)
+	logger.SetBackend(logging.AddModuleLevel(logging.NewBackendFormatter(memory, logging.MustStringFormatter("%{message}"))))
+	return logger, memory
+}
+
+func memoryMessages(memory *logging.MemoryBackend) []string {
+	messages := []string{}
+	for node := memory.Head(); node != nil; node = node.Next() {
+		messages = append(messages, node.Record.Formatted(0))
+	}
+	return messages
+}
+
+func TestSamplerFirstThenEveryNth(t *testing.T) {
+	// given
+	logger, _ := newSamplingTestLogger("sampling_test")
+	s := newSampler(&SamplingConfig{ReportInterval: 3600, Rules: []SamplingRule{{First: 2, Thereafter: 3}}})
+	defer close(s.stop)
+
+	// when
+	allowed := []bool{}
+	for i := 0; i < 8; i++ {
+		allowed = append(allowed, s.allow(logger, logging.ERROR, "[Response %s %s]"))
+	}
+	other := s.allow(logger, logging.ERROR, "[db error]:%v")
+
+	// then
+	assert.Equal(t, []bool{true, true, false, false, true, false, false, true}, allowed)
+	assert.True(t, other)
+}
+
+func TestSamplerWindowResets(t *testing.T) {
+	// given
+	logger, _ := newSamplingTestLogger("sampling_test")
+	s := newSampler(&SamplingConfig{ReportInterval: 3600, Rules: []SamplingRule{{Interval: 10, First: 1}}})
+	defer close(s.stop)
+	now := time.Now()
+	s.now = func() time.Time { return now }
+
+	// when
+	first := s.allow(logger, logging.DEBUG, "template")
+	second := s.allow(logger, logging.DEBUG, "template")
+	now = now.Add(10 * time.Second)
+	afterInterval := s.allow(logger, logging.DEBUG, "template")
+
+	// then
+	assert.True(t, first)
+	assert.False(t, second)
+	assert.True(t, afterInterval)
+}
+
+func TestSamplerRuleModules(t *testing.T) {
+	// given
+	dbLogger, _ := newSamplingTestLogger("db")
+	httpLogger, _ := newSamplingTestLogger("http")
+	s := newSampler(&SamplingConfig{ReportInterval: 3600, Rules: []SamplingRule{{Module: "db", First: 1}}})
+	defer close(s.stop)
+
+	// when
+	s.allow(dbLogger, logging.DEBUG, "template")
+	db := s.allow(dbLogger, logging.DEBUG, "template")
+	s.allow(httpLogger, logging.DEBUG, "template")
+	http := s.allow(httpLogger, logging.DEBUG, "template")
+
+	// then
+	assert.False(t, db)
+	assert.True(t, http)
+}
+
+func TestSamplerReportsSuppressed(t *testing.T) {
+	// given
+	logger, memory := newSamplingTestLogger("sampling_report_test")
+	s := newSampler(&SamplingConfig{ReportInterval: 3600, Rules: []SamplingRule{{First: 1}}})
+	defer close(s.stop)
+	for i := 0; i < 5; i++ {
+		s.allow(logger, logging.WARNING, "[db error]:%v")
+	}
+
+	// when
+	s.report()
+	s.report()
+
+	// then
+	assert.Equal(t, []string{`[sampling] suppressed 4 messages like "[db error]:%v"`}, memoryMessages(memory))
+}
+
+func TestLogWithFieldsSampled(t *testing.T) {
+	// given
+	logger, memory := newSamplingTestLogger("sampling_fields_test")
+	s := newSampler(&SamplingConfig{ReportInterval: 3600, Rules: []SamplingRule{{First: 1}}})
+	setSampler(s)
+
+	// when
+	logWithFields(logger, logging.ERROR, Fields{"status": 500}, "request %d failed", 1)
+	logWithFields(logger, logging.ERROR, Fields{"status": 500}, "request %d failed", 2)
+	NewFieldLogger(logger).Errorf("field logger %d", 1)
+	NewFieldLogger(logger).Errorf("field logger %d", 2)
+	setSampler(nil)
+
+	// then
+	messages := memoryMessages(memory)
+	assert.Len(t, messages, 4)
+	assert.Equal(t, []string{"request 1 failed", "field logger 1"}, messages[:2])
+	assert.ElementsMatch(t, []string{
+		`[sampling] suppressed 1 messages like "request %d failed"`,
+		`[sampling] suppressed 1 messages like "field logger %d"`,
+	}, messages[2:])
+}
+
+func TestInvalidSamplingConfig(t *testing.T) {
+	// given
+	configs := []*SamplingConfig{
+		{ReportInterval: -1},
+		{Rules: []SamplingRule{{First: -1}}},
+		{Rules: []SamplingRule{{Interval: -1}}},
+		{Rules: []SamplingRule{{Thereafter: -1}}},
+	}
+
+	// when / then
+	for _, config := range configs {
+		assert.Error(t, config.Validate())
+	}
+	assert.NoError(t, (&SamplingConfig{Rules: []SamplingRule{{Module: "db", First: 10, Thereafter: 100}}}).Validate())
+}
+
+func TestLogErrorSampled(t *testing.T) {
+	// given
+	logger, memory := newSamplingTestLogger("sampling_error_test")
+	setSampler(newSampler(&SamplingConfig{ReportInterval: 3600, Rules: []SamplingRule{{First: 1}}}))
+	defer setSampler(nil)
+
+	// when
+	logError(logger, nil, errors.New("connection refused"), http.StatusServiceUnavailable)
+	logError(logger, nil, errors.New("connection reset"), http.StatusServiceUnavailable)
+
+	// then
+	messages := memoryMessages(memory)
+	assert.Len(t, messages, 1)
+	assert.Contains(t, messages[0], "connection refused")
+}
@@ -17,6 +17,7 @@ package goserv
 import (
 	"errors"
 	"fmt"
+	"io"
 	"log/syslog"
 	"os"
 	"strings"
@@ -24,6 +25,13 @@ import (
 	"github.com/op/go-logging"
 )
 
+const (
+	// FormatterText renders log records using the printf style Format of the logging configuration
+	FormatterText = "TEXT"
+	// FormatterJSON renders each log record as a single line JSON object
+	FormatterJSON = "JSON"
+)
+
 // DefaultLoggingConfig represents a suitable logging default for development
 var DefaultLoggingConfig = LoggingConfig{
 	LogLevel:    "DEBUG",
@@ -39,11 +47,19 @@ var DefaultLoggingConfig = LoggingConfig{
 
 // LoggingConfig contains configuration for op/go-logging
 type LoggingConfig struct {
-	LogLevel    string          `json:"log_level"`
-	LogDB       bool            `json:"log_db"`
-	LogEndpoint bool            `json:"log_endpoint"`
-	Format      string          `json:"format"`
-	Backends    []BackendConfig `json:"backends"`
+	LogLevel string `json:"log_level"`
+	// ModuleLevels overrides the log level of specific modules, ie {"db": "DEBUG", "http": "WARNING"}
+	ModuleLevels map[string]string `json:"module_levels"`
+	LogDB        bool              `json:"log_db"`
+	LogEndpoint  bool              `json:"log_endpoint"`
+	Format       string            `json:"format"`
+	Backends     []BackendConfig   `json:"backends"`
+	// BodyLogging configures body logging and redaction by the RestfulLoggingFilter, see RestfulLoggingFilter.SetBodyLogging
+	BodyLogging *BodyLoggingConfig `json:"body_logging"`
+	// AccessLog configures the AccessLogFilter, see NewAccessLogFilter
+	AccessLog *AccessLogConfig `json:"access_log"`
+	// Sampling limits the number of repeated messages logged, see SamplingConfig
+	Sampling *SamplingConfig `json:"sampling"`
 }
 
 // Validate ensures a configuration has populated all required fields.
@@ -51,27 +67,44 @@ func (l *LoggingConfig) Validate() error {
 	if _, err := logging.LogLevel(l.LogLevel); err != nil {
 		return err
 	}
+	for module, level := range l.ModuleLevels {
+		if _, err := logging.LogLevel(level); err != nil {
+			return fmt.Errorf("invalid log level %s for module %s", level, module)
+		}
+	}
 	if l.Format != "" {
 		if _, err := logging.NewStringFormatter(l.Format); err != nil {
 			return err
 		}
 	}
+	if l.BodyLogging != nil {
+		if err := l.BodyLogging.Validate(); err != nil {
+			return err
+		}
+	}
+	if l.AccessLog != nil {
+		if err := l.AccessLog.Validate(); err != nil {
+			return err
+		}
+	}
+	if l.Sampling != nil {
+		if err := l.Sampling.Validate(); err != nil {
+			return err
+		}
+	}
 	if len(l.Backends) == 0 {
 		return errors.New("no logging backends defined")
 	}
 	for _, backend := range l.Backends {
-		if backend.BackendName != "STDOUT" &&
-			backend.BackendName != "SYSLOG" &&
-			backend.BackendName != "FILE" {
-			return fmt.Errorf("invalid backend name %s", backend.BackendName)
-		} else if backend.BackendName == "FILE" && backend.FilePath == "" {
-			return fmt.Errorf("file backend requires a file path to be set")
+		if err := backend.Validate(); err != nil {
+			return err
 		}
 	}
 	return nil
 }
 
-// InitializeLogging configures logging based on the logging configuration.
+// InitializeLogging configures logging based on the logging configuration. Module levels may be changed at runtime using SetLogLevel or the LoggingService.
+// Call FlushLogging on shutdown to write records queued by asynchronous backends. Initializing logging again closes the files and connections of the previous backends.
 func (l *LoggingConfig) InitializeLogging() error {
 	if l.Format != "" {
 		format := logging.MustStringFormatter(l.Format)
@@ -82,16 +115,50 @@ func (l *LoggingConfig) InitializeLogging() error {
 		return err
 	}
 
+	levels := newModuleLevels(nil)
+	levels.SetLevel(level, "")
+	for module, moduleLevel := range l.ModuleLevels {
+		parsed, _ := logging.LogLevel(moduleLevel)
+		levels.SetLevel(parsed, module)
+	}
 	backends := []logging.Backend{}
+	async := []*AsyncBackend{}
+	closers := []io.Closer{}
 	for _, b := range l.Backends {
-		backend, err := b.getBackend(level)
+		filter := &backendLevelFilter{levels: levels}
+		backendLevel := level
+		if b.LogLevel != "" {
+			backendLevel, _ = logging.LogLevel(b.LogLevel)
+			filter.level = &backendLevel
+			if backendLevel > levels.verbose {
+				levels.verbose = backendLevel
+			}
+		}
+		backend, asyncBackend, closer, err := b.newBackend(backendLevel, b.formatter())
 		if err != nil {
+			closeAsyncBackends(async)
+			closeAll(closers)
 			return err
 		}
-		backends = append(backends, backend)
+		if asyncBackend != nil {
+			async = append(async, asyncBackend)
+		}
+		if closer != nil {
+			closers = append(closers, closer)
+		}
+		filter.backend = backend
+		backends = append(backends, filter)
+	}
+	// the multi logger also applies the formatter of the logging configuration to backends without a formatter of their own
+	levels.backend = logging.MultiLogger(backends...)
+	logging.SetBackend(levels)
+	setLogLevels(levels)
+	setAsyncBackends(loggingAsyncBackends, async, closers, DefaultAsyncCloseTimeout)
+	if l.Sampling != nil {
+		setSampler(newSampler(l.Sampling))
+	} else {
+		setSampler(nil)
 	}
-	logging.SetBackend(backends...)
-	logging.SetLevel(level, "")
 	return nil
 }
 
@@ -99,27 +166,141 @@ func (l *LoggingConfig) InitializeLogging() error {
 type BackendConfig struct {
 	BackendName string `json:"backend_name"`
 	FilePath    string `json:"file_path"`
+	// LogLevel is the minimum level logged by the backend, which may be more verbose than the log level of the logging configuration. Modules without a level of their own log to the backend at this level.
+	// If not set, the backend logs each module at its module level, defaulting to the log level, so that module levels changed at runtime apply. A module level never makes a backend log records below its LogLevel.
+	LogLevel string `json:"log_level"`
+	// Formatter is one of [TEXT, JSON], defaulting to TEXT
+	Formatter string `json:"formatter"`
+	// Format is the printf style format of the TEXT formatter, defaulting to the Format of the logging configuration
+	Format string `json:"format"`
+	// Rotation enables rotation of the FILE backend, if set
+	Rotation *FileRotationConfig `json:"rotation"`
+	// Async writes records from a background goroutine through a bounded queue, if set
+	Async *AsyncConfig `json:"async"`
+	// Syslog configures the facility and tag of the SYSLOG backend, and optionally a remote server to ship records to
+	Syslog *SyslogConfig `json:"syslog"`
 }
 
-// Returns a suitable logging backend for the backend name or an error if a backend name does not describe a logging backend.
-func (b *BackendConfig) getBackend(level logging.Level) (logging.Backend, error) {
+// Validate ensures a backend configuration is valid
+func (b *BackendConfig) Validate() error {
+	if b.BackendName != "STDOUT" &&
+		b.BackendName != "SYSLOG" &&
+		b.BackendName != "FILE" {
+		return fmt.Errorf("invalid backend name %s", b.BackendName)
+	} else if b.BackendName == "FILE" && b.FilePath == "" {
+		return fmt.Errorf("file backend requires a file path to be set")
+	}
+	if b.LogLevel != "" {
+		if _, err := logging.LogLevel(b.LogLevel); err != nil {
+			return fmt.Errorf("invalid log level %s for backend %s", b.LogLevel, b.BackendName)
+		}
+	}
+	if b.Format != "" {
+		if b.Formatter == FormatterJSON {
+			return fmt.Errorf("a format cannot be set for backend %s using the JSON formatter", b.BackendName)
+		}
+		if _, err := logging.NewStringFormatter(b.Format); err != nil {
+			return err
+		}
+	}
+	if b.Formatter != "" && b.Formatter != FormatterText && b.Formatter != FormatterJSON {
+		return fmt.Errorf("invalid formatter %s, must be one of [TEXT, JSON]", b.Formatter)
+	}
+	if b.Syslog != nil {
+		if b.BackendName != "SYSLOG" {
+			return fmt.Errorf("syslog settings are only supported by the syslog backend")
+		}
+		if err := b.Syslog.Validate(); err != nil {
+			return err
+		}
+	}
+	if b.Async != nil {
+		if err := b.Async.Validate(); err != nil {
+			return err
+		}
+	}
+	if b.Rotation != nil {
+		if b.BackendName != "FILE" {
+			return fmt.Errorf("rotation is only supported by the file backend")
+		}
+		if err := b.Rotation.Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// newBackend returns the backend of the configuration wrapped by the formatter, if not nil, along with the asynchronous backend if writes are asynchronous and the closer of the file or connection of the backend, if any
+func (b *BackendConfig) newBackend(level logging.Level, formatter logging.Formatter) (logging.Backend, *AsyncBackend, io.Closer, error) {
+	backend, closer, err := b.getBackend(level)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	var asyncBackend *AsyncBackend
+	if b.Async != nil {
+		asyncBackend = NewAsyncBackend(b.BackendName, backend, b.Async)
+		backend = asyncBackend
+	}
+	if formatter != nil {
+		backend = logging.NewBackendFormatter(backend, formatter)
+	}
+	return backend, asyncBackend, closer, nil
+}
+
+// formatter returns the formatter of the backend, or nil if the backend uses the Format of the logging configuration
+func (b *BackendConfig) formatter() logging.Formatter {
+	if b.Formatter == FormatterJSON {
+		return NewJSONFormatter()
+	} else if b.Format != "" {
+		return logging.MustStringFormatter(b.Format)
+	}
+	return nil
+}
+
+// Returns a suitable logging backend for the backend name, along with the closer of its file or connection if any, or an error if a backend name does not describe a logging backend. The level of the backend determines the default syslog priority.
+func (b *BackendConfig) getBackend(level logging.Level) (logging.Backend, io.Closer, error) {
 	switch {
 	case strings.EqualFold(b.BackendName, "STDOUT"):
-		return logging.NewLogBackend(os.Stdout, "", 0), nil
+		return logging.NewLogBackend(os.Stdout, "", 0), nil, nil
 	case strings.EqualFold(b.BackendName, "FILE"):
+		if b.FilePath != "" && b.Rotation != nil {
+			writer, err := NewRotatingFileWriter(b.FilePath, b.Rotation)
+			if err != nil {
+				return nil, nil, err
+			}
+			return logging.NewLogBackend(writer, "", 0), writer, nil
+		}
 		if b.FilePath != "" {
 			file, err := os.OpenFile(b.FilePath, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
 			if err != nil {
-				return nil, err
+				return nil, nil, err
 			}
-			return logging.NewLogBackend(file, "", 0), nil
+			return logging.NewLogBackend(file, "", 0), file, nil
 		}
-		return nil, errors.New("Error using file as a backend")
+		return nil, nil, errors.New("Error using file as a backend")
 	case strings.EqualFold(b.BackendName, "SYSLOG"):
-		b, _ := logging.NewSyslogBackendPriority("", toSyslogPriority(level))
-		return b, nil
+		if b.Syslog != nil && b.Syslog.Network != "" {
+			backend, err := NewRemoteSyslogBackend(b.Syslog)
+			if err != nil {
+				return nil, nil, err
+			}
+			return backend, backend, nil
+		}
+		tag, priority := "", toSyslogPriority(level)
+		if b.Syslog != nil {
+			facility, err := b.Syslog.facility()
+			if err != nil {
+				return nil, nil, err
+			}
+			tag, priority = b.Syslog.tag(), facility|priority
+		}
+		backend, err := logging.NewSyslogBackendPriority(tag, priority)
+		if err != nil {
+			return nil, nil, err
+		}
+		return backend, backend.Writer, nil
 	}
-	return nil, errors.New("Error creating the backend for logging")
+	return nil, nil, errors.New("Error creating the backend for logging")
 }
 
 func toSyslogPriority(level logging.Level) syslog.Priority {
@@ -15,8 +15,15 @@
 package goserv
 
 import (
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/op/go-logging"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -142,3 +149,273 @@ func TestValidFileBackendLoggingConfig(t *testing.T) {
 	// then
 	assert.NoError(t, err)
 }
+
+func TestInvalidFormatterLoggingConfig(t *testing.T) {
+	// given
+	config := &LoggingConfig{
+		LogLevel: "DEBUG",
+		Backends: []BackendConfig{
+			BackendConfig{
+				BackendName: "STDOUT",
+				Formatter:   "XML",
+			},
+		},
+	}
+
+	// when
+	err := config.Validate()
+
+	// then
+	assert.Error(t, err)
+}
+
+func TestInvalidRotationLoggingConfig(t *testing.T) {
+	// given
+	config := &LoggingConfig{
+		LogLevel: "DEBUG",
+		Backends: []BackendConfig{
+			BackendConfig{
+				BackendName: "STDOUT",
+				Rotation:    &FileRotationConfig{MaxSize: 1024},
+			},
+		},
+	}
+
+	// when
+	err := config.Validate()
+
+	// then
+	assert.Error(t, err)
+}
+
+func TestInvalidModuleLevelLoggingConfig(t *testing.T) {
+	// given
+	config := &LoggingConfig{
+		LogLevel:     "DEBUG",
+		ModuleLevels: map[string]string{"db": "VERBOSE"},
+		Backends: []BackendConfig{
+			BackendConfig{
+				BackendName: "STDOUT",
+			},
+		},
+	}
+
+	// when
+	err := config.Validate()
+
+	// then
+	assert.Error(t, err)
+}
+
+func TestInvalidBackendLevelLoggingConfig(t *testing.T) {
+	// given
+	config := &LoggingConfig{
+		LogLevel: "DEBUG",
+		Backends: []BackendConfig{
+			BackendConfig{
+				BackendName: "STDOUT",
+				LogLevel:    "VERBOSE",
+			},
+		},
+	}
+
+	// when
+	err := config.Validate()
+
+	// then
+	assert.Error(t, err)
+}
+
+func TestInvalidJSONBackendFormatLoggingConfig(t *testing.T) {
+	// given
+	config := &LoggingConfig{
+		LogLevel: "DEBUG",
+		Backends: []BackendConfig{
+			BackendConfig{
+				BackendName: "STDOUT",
+				Formatter:   FormatterJSON,
+				Format:      "%{message}",
+			},
+		},
+	}
+
+	// when
+	err := config.Validate()
+
+	// then
+	assert.Error(t, err)
+}
+
+func TestBackendLevelsAndFormats(t *testing.T) {
+	// given
+	dir, _ := ioutil.TempDir("", "logging")
+	defer os.RemoveAll(dir)
+	defer logging.SetBackend(logging.NewLogBackend(os.Stderr, "", 0))
+	defer setLogLevels(nil)
+	defer setAsyncBackends(loggingAsyncBackends, nil, nil, time.Second)
+	config := &LoggingConfig{
+		LogLevel: "DEBUG",
+		Backends: []BackendConfig{
+			BackendConfig{
+				BackendName: "FILE",
+				FilePath:    filepath.Join(dir, "errors.log"),
+				LogLevel:    "ERROR",
+				Format:      "%{level} %{message}",
+			},
+			BackendConfig{
+				BackendName: "FILE",
+				FilePath:    filepath.Join(dir, "debug.log"),
+				Formatter:   FormatterJSON,
+				Async:       &AsyncConfig{QueueSize: 16},
+			},
+		},
+	}
+	assert.NoError(t, config.Validate())
+	assert.NoError(t, config.InitializeLogging())
+	logger := logging.MustGetLogger("backend_levels_test")
+
+	// when
+	logger.Debug("checking cache")
+	logger.Error("cache unavailable")
+	assert.NoError(t, FlushLogging(time.Second))
+
+	// then
+	errorLog, _ := ioutil.ReadFile(filepath.Join(dir, "errors.log"))
+	assert.Equal(t, "ERROR cache unavailable\n", string(errorLog))
+	debug, _ := ioutil.ReadFile(filepath.Join(dir, "debug.log"))
+	lines := strings.Split(strings.TrimSpace(string(debug)), "\n")
+	assert.Len(t, lines, 2)
+	assert.Contains(t, lines[0], `"message":"checking cache"`)
+	assert.Contains(t, lines[0], `"file":"logging_config_test.go:`)
+	assert.Len(t, LoggingStats(), 1)
+}
+
+func TestBackendLevelMoreVerboseThanLogLevel(t *testing.T) {
+	// given
+	dir, _ := ioutil.TempDir("", "logging")
+	defer os.RemoveAll(dir)
+	defer logging.SetBackend(logging.NewLogBackend(os.Stderr, "", 0))
+	defer setLogLevels(nil)
+	config := &LoggingConfig{
+		LogLevel: "INFO",
+		Format:   "%{level} %{message}",
+		Backends: []BackendConfig{
+			BackendConfig{
+				BackendName: "FILE",
+				FilePath:    filepath.Join(dir, "info.log"),
+			},
+			BackendConfig{
+				BackendName: "FILE",
+				FilePath:    filepath.Join(dir, "debug.log"),
+				LogLevel:    "DEBUG",
+			},
+		},
+	}
+	assert.NoError(t, config.Validate())
+	assert.NoError(t, config.InitializeLogging())
+	logger := logging.MustGetLogger("backend_verbose_test")
+
+	// when
+	logger.Debug("checking cache")
+	logger.Info("cache ready")
+
+	// then
+	info, _ := ioutil.ReadFile(filepath.Join(dir, "info.log"))
+	assert.Equal(t, "INFO cache ready\n", string(info))
+	debug, _ := ioutil.ReadFile(filepath.Join(dir, "debug.log"))
+	assert.Equal(t, "DEBUG checking cache\nINFO cache ready\n", string(debug))
+}
+
+func TestModuleLevelsApplyToBackendsWithoutLevel(t *testing.T) {
+	// given
+	dir, _ := ioutil.TempDir("", "logging")
+	defer os.RemoveAll(dir)
+	defer logging.SetBackend(logging.NewLogBackend(os.Stderr, "", 0))
+	defer setLogLevels(nil)
+	config := &LoggingConfig{
+		LogLevel:     "INFO",
+		ModuleLevels: map[string]string{"module_levels_db": "DEBUG"},
+		Format:       "%{module} %{level} %{message}",
+		Backends: []BackendConfig{
+			BackendConfig{
+				BackendName: "FILE",
+				FilePath:    filepath.Join(dir, "app.log"),
+			},
+			BackendConfig{
+				BackendName: "FILE",
+				FilePath:    filepath.Join(dir, "errors.log"),
+				LogLevel:    "ERROR",
+			},
+		},
+	}
+	assert.NoError(t, config.Validate())
+	assert.NoError(t, config.InitializeLogging())
+	db := logging.MustGetLogger("module_levels_db")
+	http := logging.MustGetLogger("module_levels_http")
+
+	// when
+	db.Debug("query executed")
+	http.Debug("request dropped")
+	assert.NoError(t, SetLogLevel("module_levels_http", logging.DEBUG, time.Minute))
+	http.Debug("request received")
+
+	// then
+	app, _ := ioutil.ReadFile(filepath.Join(dir, "app.log"))
+	assert.Equal(t, "module_levels_db DEBUG query executed\nmodule_levels_http DEBUG request received\n", string(app))
+	errorLog, _ := ioutil.ReadFile(filepath.Join(dir, "errors.log"))
+	assert.Empty(t, string(errorLog))
+}
+
+func TestInitializeLoggingClosesPreviousBackends(t *testing.T) {
+	// given
+	dir, _ := ioutil.TempDir("", "logging")
+	defer os.RemoveAll(dir)
+	defer logging.SetBackend(logging.NewLogBackend(os.Stderr, "", 0))
+	defer setLogLevels(nil)
+	defer setAsyncBackends(loggingAsyncBackends, nil, nil, time.Second)
+	config := &LoggingConfig{
+		LogLevel: "INFO",
+		Backends: []BackendConfig{
+			BackendConfig{BackendName: "FILE", FilePath: filepath.Join(dir, "app.log")},
+			BackendConfig{BackendName: "FILE", FilePath: filepath.Join(dir, "rotated.log"), Rotation: &FileRotationConfig{ReopenOnSIGHUP: true}},
+		},
+	}
+	assert.NoError(t, config.InitializeLogging())
+	closers := append([]io.Closer{}, backendClosers[loggingAsyncBackends]...)
+	assert.Len(t, closers, 2)
+
+	// when
+	err := config.InitializeLogging()
+
+	// then
+	assert.NoError(t, err)
+	_, fileErr := closers[0].(*os.File).Write([]byte("after\n"))
+	assert.Error(t, fileErr)
+	_, writerErr := closers[1].(*RotatingFileWriter).Write([]byte("after\n"))
+	assert.Equal(t, os.ErrClosed, writerErr)
+	sighupLock.Lock()
+	defer sighupLock.Unlock()
+	assert.NotContains(t, sighupWriters, closers[1])
+}
+
+func TestSingleBackendUsesLoggingFormat(t *testing.T) {
+	// given
+	dir, _ := ioutil.TempDir("", "logging")
+	defer os.RemoveAll(dir)
+	defer logging.SetBackend(logging.NewLogBackend(os.Stderr, "", 0))
+	defer setLogLevels(nil)
+	config := &LoggingConfig{
+		LogLevel: "INFO",
+		Format:   "%{level} %{message}",
+		Backends: []BackendConfig{BackendConfig{BackendName: "FILE", FilePath: filepath.Join(dir, "app.log")}},
+	}
+	assert.NoError(t, config.InitializeLogging())
+	logger := logging.MustGetLogger("single_backend_test")
+
+	// when
+	logger.Info("cache ready")
+
+	// then
+	app, _ := ioutil.ReadFile(filepath.Join(dir, "app.log"))
+	assert.Equal(t, "INFO cache ready\n", string(app))
+}
@@ -0,0 +1,196 @@
+// Copyright 2020 Daniel Akiva
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goserv
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/op/go-logging"
+)
+
+// Fields holds structured logging fields. The JSON formatter emits fields as members of the log entry, text formats append them as key=value pairs.
+type Fields map[string]interface{}
+
+// String renders the fields as space separated key=value pairs sorted by key, prefixed with a space
+func (f Fields) String() string {
+	keys := make([]string, 0, len(f))
+	for key := range f {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	var builder strings.Builder
+	for _, key := range keys {
+		value := fmt.Sprint(f[key])
+		if value == "" || strings.ContainsAny(value, " =\"") {
+			value = strconv.Quote(value)
+		}
+		builder.WriteString(" " + key + "=" + value)
+	}
+	return builder.String()
+}
+
+// structuredFields holds fields that are only emitted by structured formatters, for log statements whose text message already carries the same values
+type structuredFields Fields
+
+// String renders nothing, the text message already carries the values
+func (s structuredFields) String() string { return "" }
+
+// FieldLogger logs messages along with structured fields. A FieldLogger is immutable, WithField and WithFields return a new logger.
+type FieldLogger struct {
+	logger logging.Logger
+	fields Fields
+}
+
+// NewFieldLogger initializes a new field logger writing to a logger
+func NewFieldLogger(logger *logging.Logger) *FieldLogger {
+	wrapped := *logger
+	// skip the level method, log and logAtLevel frames so that the file of the caller is reported
+	wrapped.ExtraCalldepth += 3
+	return &FieldLogger{logger: wrapped, fields: Fields{}}
+}
+
+// WithField returns a logger that adds a field to every message
+func (f *FieldLogger) WithField(key string, value interface{}) *FieldLogger {
+	return f.WithFields(Fields{key: value})
+}
+
+// WithFields returns a logger that adds the fields to every message, replacing existing fields with the same key
+func (f *FieldLogger) WithFields(fields Fields) *FieldLogger {
+	merged := make(Fields, len(f.fields)+len(fields))
+	for key, value := range f.fields {
+		merged[key] = value
+	}
+	for key, value := range fields {
+		merged[key] = value
+	}
+	return &FieldLogger{logger: f.logger, fields: merged}
+}
+
+// IsEnabledFor returns true if messages are logged at the level
+func (f *FieldLogger) IsEnabledFor(level logging.Level) bool {
+	return f.logger.IsEnabledFor(level)
+}
+
+// Critical logs a message at the critical level
+func (f *FieldLogger) Critical(message string) { f.log(logging.CRITICAL, message, message) }
+
+// Criticalf logs a formatted message at the critical level
+func (f *FieldLogger) Criticalf(format string, args ...interface{}) {
+	f.log(logging.CRITICAL, format, fmt.Sprintf(format, args...))
+}
+
+// Error logs a message at the error level
+func (f *FieldLogger) Error(message string) { f.log(logging.ERROR, message, message) }
+
+// Errorf logs a formatted message at the error level
+func (f *FieldLogger) Errorf(format string, args ...interface{}) {
+	f.log(logging.ERROR, format, fmt.Sprintf(format, args...))
+}
+
+// Warning logs a message at the warning level
+func (f *FieldLogger) Warning(message string) { f.log(logging.WARNING, message, message) }
+
+// Warningf logs a formatted message at the warning level
+func (f *FieldLogger) Warningf(format string, args ...interface{}) {
+	f.log(logging.WARNING, format, fmt.Sprintf(format, args...))
+}
+
+// Notice logs a message at the notice level
+func (f *FieldLogger) Notice(message string) { f.log(logging.NOTICE, message, message) }
+
+// Noticef logs a formatted message at the notice level
+func (f *FieldLogger) Noticef(format string, args ...interface{}) {
+	f.log(logging.NOTICE, format, fmt.Sprintf(format, args...))
+}
+
+// Info logs a message at the info level
+func (f *FieldLogger) Info(message string) { f.log(logging.INFO, message, message) }
+
+// Infof logs a formatted message at the info level
+func (f *FieldLogger) Infof(format string, args ...interface{}) {
+	f.log(logging.INFO, format, fmt.Sprintf(format, args...))
+}
+
+// Debug logs a message at the debug level
+func (f *FieldLogger) Debug(message string) { f.log(logging.DEBUG, message, message) }
+
+// Debugf logs a formatted message at the debug level
+func (f *FieldLogger) Debugf(format string, args ...interface{}) {
+	f.log(logging.DEBUG, format, fmt.Sprintf(format, args...))
+}
+
+// log logs a message unless it is suppressed by sampling, where messages are sampled by their template
+func (f *FieldLogger) log(level logging.Level, template string, message string) {
+	if !f.logger.IsEnabledFor(level) || !sampled(&f.logger, level, template) {
+		return
+	}
+	logAtLevel(&f.logger, level, "%s%v", message, f.fields)
+}
+
+// logWithFields logs a text message along with fields that only structured formatters emit. The message is formatted up front so that the fields can be told apart from the message arguments.
+// Messages are sampled by their format, see SamplingConfig.
+func logWithFields(logger *logging.Logger, level logging.Level, fields Fields, format string, args ...interface{}) {
+	if !logger.IsEnabledFor(level) || !sampled(logger, level, format) {
+		return
+	}
+	wrapped := *logger
+	// skip this function and logAtLevel so that the file of the caller is reported
+	wrapped.ExtraCalldepth += 2
+	logAtLevel(&wrapped, level, "%s%v", fmt.Sprintf(format, args...), structuredFields(fields))
+}
+
+func logAtLevel(logger *logging.Logger, level logging.Level, format string, args ...interface{}) {
+	switch level {
+	case logging.CRITICAL:
+		logger.Criticalf(format, args...)
+	case logging.ERROR:
+		logger.Errorf(format, args...)
+	case logging.WARNING:
+		logger.Warningf(format, args...)
+	case logging.NOTICE:
+		logger.Noticef(format, args...)
+	case logging.INFO:
+		logger.Infof(format, args...)
+	default:
+		logger.Debugf(format, args...)
+	}
+}
+
+// recordFields returns the message and fields of a record logged with fields, or false if the record carries no fields
+func recordFields(record *logging.Record) (string, Fields, bool) {
+	if len(record.Args) != 2 {
+		return "", nil, false
+	}
+	message, ok := record.Args[0].(string)
+	if !ok {
+		return "", nil, false
+	}
+	switch fields := record.Args[1].(type) {
+	case Fields:
+		return message, fields, true
+	case structuredFields:
+		return message, Fields(fields), true
+	}
+	return "", nil, false
+}
+
+// durationMillis converts a duration to fractional milliseconds, the unit used by duration fields
+func durationMillis(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
@@ -0,0 +1,341 @@
+// Copyright 2020 Daniel Akiva
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goserv
+
+import (
+	"encoding"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emicklei/go-restful"
+)
+
+var (
+	boundParameterCache sync.Map
+	timeType            = reflect.TypeOf(time.Time{})
+	durationType        = reflect.TypeOf(time.Duration(0))
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// boundParameter represents a struct field bound to a path, query or header parameter
+type boundParameter struct {
+	index        int
+	kind         int
+	name         string
+	required     bool
+	defaultValue string
+	enum         []string
+	layout       string
+	description  string
+}
+
+// BindParameters fills a pointer to a struct from the path, query and header parameters of a request, as declared by the path, query and header struct tags.
+// Fields may also declare a default, a comma separated enum, a time layout (defaulting to RFC 3339), whether they are required and a description using the default, enum, layout, required and description tags. For example:
+//
+//	type ListParams struct {
+//		ID     int           `path:"id"`
+//		Limit  int           `query:"limit" default:"20"`
+//		Order  string        `query:"order" enum:"asc,desc" default:"asc"`
+//		Since  *time.Time    `query:"since"`
+//		Tags   []string      `query:"tag"`
+//		Wait   time.Duration `header:"X-Wait" required:"true"`
+//	}
+//
+// Supported field types are strings, integers, floats, booleans, times, durations, encoding.TextUnmarshaler implementations, and pointers and slices of those.
+// Slices accept repeated query parameters as well as comma separated values. Returns an IllegalArgumentError naming the parameter if a value is missing or invalid.
+func BindParameters(request *restful.Request, params interface{}) error {
+	v := reflect.ValueOf(params)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("parameters must be a pointer to a struct, got %T", params)
+	}
+	v = v.Elem()
+	fields, err := boundParameters(v.Type())
+	if err != nil {
+		return err
+	}
+	for _, field := range fields {
+		values := field.values(request)
+		if len(values) == 0 {
+			if field.required {
+				return &IllegalArgumentError{Argument: field.name, Err: fmt.Errorf("%v parameter is required", field.name)}
+			}
+			if field.defaultValue == "" {
+				continue
+			}
+			values = []string{field.defaultValue}
+		}
+		if err := field.set(v.Field(field.index), values); err != nil {
+			return &IllegalArgumentError{Argument: field.name, Err: err}
+		}
+	}
+	return nil
+}
+
+// ParametersOf returns the go-restful parameter definitions matching the tags of a struct, so that routes document exactly the parameters bound by BindParameters.
+func ParametersOf(params interface{}) ([]*restful.Parameter, error) {
+	t := reflect.TypeOf(params)
+	for t != nil && t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("parameters must be a struct, got %T", params)
+	}
+	fields, err := boundParameters(t)
+	if err != nil {
+		return nil, err
+	}
+	parameters := make([]*restful.Parameter, len(fields))
+	for i, field := range fields {
+		parameters[i] = field.parameter(t.Field(field.index).Type)
+	}
+	return parameters, nil
+}
+
+// RouteParameters adds the parameter definitions of a struct to a route. Panics if the struct declares invalid parameters, as routes are built at startup.
+func RouteParameters(builder *restful.RouteBuilder, params interface{}) *restful.RouteBuilder {
+	parameters, err := ParametersOf(params)
+	if err != nil {
+		panic(err)
+	}
+	for _, parameter := range parameters {
+		builder.Param(parameter)
+	}
+	return builder
+}
+
+func (b *boundParameter) values(request *restful.Request) []string {
+	var raw []string
+	switch b.kind {
+	case restful.PathParameterKind:
+		raw = []string{request.PathParameter(b.name)}
+	case restful.QueryParameterKind:
+		raw = request.QueryParameters(b.name)
+	case restful.HeaderParameterKind:
+		raw = request.Request.Header.Values(b.name)
+	}
+	values := []string{}
+	for _, value := range raw {
+		if value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+func (b *boundParameter) set(v reflect.Value, values []string) error {
+	if v.Kind() == reflect.Slice && v.Type().Elem().Kind() != reflect.Uint8 {
+		items := []string{}
+		for _, value := range values {
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+		}
+		slice := reflect.MakeSlice(v.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := b.setScalar(slice.Index(i), item); err != nil {
+				return err
+			}
+		}
+		v.Set(slice)
+		return nil
+	}
+	if len(values) > 1 {
+		return fmt.Errorf("%v parameter must have a single value", b.name)
+	}
+	return b.setScalar(v, values[0])
+}
+
+func (b *boundParameter) setScalar(v reflect.Value, value string) error {
+	if len(b.enum) > 0 && !methodMatch(b.enum, value) {
+		return fmt.Errorf("%v parameter must be one of [%v], got %v", b.name, strings.Join(b.enum, ", "), value)
+	}
+	if v.Kind() == reflect.Ptr {
+		ptr := reflect.New(v.Type().Elem())
+		if err := b.setScalar(ptr.Elem(), value); err != nil {
+			return err
+		}
+		v.Set(ptr)
+		return nil
+	}
+	if v.CanAddr() && v.Addr().Type().Implements(textUnmarshalerType) && v.Type() != timeType {
+		if err := v.Addr().Interface().(encoding.TextUnmarshaler).UnmarshalText([]byte(value)); err != nil {
+			return fmt.Errorf("invalid %v parameter %v: %w", b.name, value, err)
+		}
+		return nil
+	}
+	switch {
+	case v.Type() == timeType:
+		t, err := time.Parse(b.layout, value)
+		if err != nil {
+			return fmt.Errorf("%v parameter must be a time formatted as %v, got %v", b.name, b.layout, value)
+		}
+		v.Set(reflect.ValueOf(t))
+		return nil
+	case v.Type() == durationType:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%v parameter must be a duration, got %v", b.name, value)
+		}
+		v.SetInt(int64(d))
+		return nil
+	}
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(value)
+	case reflect.Bool:
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%v parameter must be a boolean, got %v", b.name, value)
+		}
+		v.SetBool(parsed)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		parsed, err := strconv.ParseInt(value, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%v parameter must be an integer, got %v", b.name, value)
+		}
+		v.SetInt(parsed)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		parsed, err := strconv.ParseUint(value, 10, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%v parameter must be a non negative integer, got %v", b.name, value)
+		}
+		v.SetUint(parsed)
+	case reflect.Float32, reflect.Float64:
+		parsed, err := strconv.ParseFloat(value, v.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("%v parameter must be a number, got %v", b.name, value)
+		}
+		v.SetFloat(parsed)
+	default:
+		return fmt.Errorf("unsupported parameter type %v", v.Type())
+	}
+	return nil
+}
+
+func (b *boundParameter) parameter(t reflect.Type) *restful.Parameter {
+	var parameter *restful.Parameter
+	switch b.kind {
+	case restful.PathParameterKind:
+		parameter = restful.PathParameter(b.name, b.description)
+	case restful.QueryParameterKind:
+		parameter = restful.QueryParameter(b.name, b.description)
+	default:
+		parameter = restful.HeaderParameter(b.name, b.description)
+	}
+	parameter.Required(b.required)
+	if t.Kind() == reflect.Slice {
+		t = t.Elem()
+		parameter.AllowMultiple(true)
+		if b.kind == restful.QueryParameterKind {
+			parameter.CollectionFormat(restful.CollectionFormatMulti)
+		} else {
+			parameter.CollectionFormat(restful.CollectionFormatCSV)
+		}
+	}
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	dataType, dataFormat := parameterDataType(t, b.layout)
+	parameter.DataType(dataType)
+	if dataFormat != "" {
+		parameter.DataFormat(dataFormat)
+	}
+	if b.defaultValue != "" {
+		parameter.DefaultValue(b.defaultValue)
+	}
+	if len(b.enum) > 0 {
+		allowed := make(map[string]string, len(b.enum))
+		for _, value := range b.enum {
+			allowed[value] = value
+		}
+		parameter.AllowableValues(allowed)
+	}
+	return parameter
+}
+
+// parameterDataType returns the swagger data type and format of a parameter type
+func parameterDataType(t reflect.Type, layout string) (string, string) {
+	switch {
+	case t == timeType:
+		if layout == time.RFC3339 {
+			return "string", "date-time"
+		}
+		return "string", layout
+	case t == durationType:
+		return "string", "duration"
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return "boolean", ""
+	case reflect.Int64, reflect.Uint64:
+		return "integer", "int64"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32:
+		return "integer", "int32"
+	case reflect.Float32:
+		return "number", "float"
+	case reflect.Float64:
+		return "number", "double"
+	}
+	return "string", ""
+}
+
+// boundParameters returns the fields of a struct type bound to parameters, caching them per type
+func boundParameters(t reflect.Type) ([]boundParameter, error) {
+	if cached, ok := boundParameterCache.Load(t); ok {
+		return cached.([]boundParameter), nil
+	}
+	fields := []boundParameter{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		field := boundParameter{index: i, layout: time.RFC3339, description: f.Tag.Get("description")}
+		if name := f.Tag.Get("path"); name != "" {
+			field.kind, field.name, field.required = restful.PathParameterKind, name, true
+		} else if name := f.Tag.Get("query"); name != "" {
+			field.kind, field.name = restful.QueryParameterKind, name
+		} else if name := f.Tag.Get("header"); name != "" {
+			field.kind, field.name = restful.HeaderParameterKind, name
+		} else {
+			continue
+		}
+		if f.PkgPath != "" {
+			return nil, fmt.Errorf("parameter field %s of %v must be exported", f.Name, t)
+		}
+		if required := f.Tag.Get("required"); required != "" && field.kind != restful.PathParameterKind {
+			parsed, err := strconv.ParseBool(required)
+			if err != nil {
+				return nil, fmt.Errorf("invalid required tag on field %s of %v: %w", f.Name, t, err)
+			}
+			field.required = parsed
+		}
+		if layout := f.Tag.Get("layout"); layout != "" {
+			field.layout = layout
+		}
+		if enum := f.Tag.Get("enum"); enum != "" {
+			for _, value := range strings.Split(enum, ",") {
+				field.enum = append(field.enum, strings.TrimSpace(value))
+			}
+		}
+		field.defaultValue = f.Tag.Get("default")
+		fields = append(fields, field)
+	}
+	boundParameterCache.Store(t, fields)
+	return fields, nil
+}
@@ -0,0 +1,121 @@
+// Copyright 2020 Daniel Akiva
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goserv
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/emicklei/go-restful"
+	"github.com/stretchr/testify/assert"
+)
+
+type testListParams struct {
+	ID      int           `path:"id" description:"The account id."`
+	Limit   int           `query:"limit" default:"20"`
+	Order   string        `query:"order" enum:"asc,desc" default:"asc"`
+	Since   *time.Time    `query:"since"`
+	Day     time.Time     `query:"day" layout:"2006-01-02"`
+	Tags    []string      `query:"tag"`
+	Verbose bool          `query:"verbose"`
+	Wait    time.Duration `header:"X-Wait" required:"true"`
+}
+
+func bindTestParams(url string, wait string) (*testListParams, error) {
+	params := &testListParams{}
+	var bindErr error
+	ws := new(restful.WebService)
+	ws.Route(ws.GET("/accounts/{id}").To(func(request *restful.Request, response *restful.Response) {
+		bindErr = BindParameters(request, params)
+	}))
+	container := restful.NewContainer()
+	container.Add(ws)
+	httpReq := httptest.NewRequest(http.MethodGet, url, nil)
+	if wait != "" {
+		httpReq.Header.Set("X-Wait", wait)
+	}
+	container.ServeHTTP(httptest.NewRecorder(), httpReq)
+	return params, bindErr
+}
+
+func TestBindParameters(t *testing.T) {
+	// when
+	params, err := bindTestParams("/accounts/42?since=2020-01-02T03:04:05Z&day=2020-03-04&tag=a,b&tag=c&verbose=true", "1m30s")
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, 42, params.ID)
+	assert.Equal(t, 20, params.Limit)
+	assert.Equal(t, "asc", params.Order)
+	assert.Equal(t, time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC), *params.Since)
+	assert.Equal(t, time.Date(2020, 3, 4, 0, 0, 0, 0, time.UTC), params.Day)
+	assert.Equal(t, []string{"a", "b", "c"}, params.Tags)
+	assert.True(t, params.Verbose)
+	assert.Equal(t, 90*time.Second, params.Wait)
+}
+
+func TestBindParametersInvalidValue(t *testing.T) {
+	// when
+	_, err := bindTestParams("/accounts/42?limit=ten", "1s")
+
+	// then
+	assert.IsType(t, &IllegalArgumentError{}, err)
+	assert.Equal(t, "limit", err.(*IllegalArgumentError).Argument)
+}
+
+func TestBindParametersInvalidEnum(t *testing.T) {
+	// when
+	_, err := bindTestParams("/accounts/42?order=random", "1s")
+
+	// then
+	assert.IsType(t, &IllegalArgumentError{}, err)
+	assert.Equal(t, "order", err.(*IllegalArgumentError).Argument)
+}
+
+func TestBindParametersMissingRequired(t *testing.T) {
+	// when
+	_, err := bindTestParams("/accounts/42", "")
+
+	// then
+	assert.IsType(t, &IllegalArgumentError{}, err)
+	assert.Equal(t, "X-Wait", err.(*IllegalArgumentError).Argument)
+}
+
+func TestParametersOf(t *testing.T) {
+	// when
+	parameters, err := ParametersOf(testListParams{})
+
+	// then
+	assert.NoError(t, err)
+	assert.Len(t, parameters, 8)
+	id := parameters[0].Data()
+	assert.Equal(t, restful.PathParameterKind, id.Kind)
+	assert.Equal(t, "integer", id.DataType)
+	assert.True(t, id.Required)
+	assert.Equal(t, "The account id.", id.Description)
+	order := parameters[2].Data()
+	assert.Equal(t, "asc", order.DefaultValue)
+	assert.Equal(t, map[string]string{"asc": "asc", "desc": "desc"}, order.AllowableValues)
+	since := parameters[3].Data()
+	assert.Equal(t, "date-time", since.DataFormat)
+	tags := parameters[5].Data()
+	assert.True(t, tags.AllowMultiple)
+	assert.Equal(t, "multi", tags.CollectionFormat)
+	wait := parameters[7].Data()
+	assert.Equal(t, restful.HeaderParameterKind, wait.Kind)
+	assert.True(t, wait.Required)
+}
@@ -0,0 +1,98 @@
+// Copyright 2020 Daniel Akiva
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goserv
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+)
+
+const (
+	// MIMEProblemJSON is the RFC 7807 problem details media type
+	MIMEProblemJSON = "application/problem+json"
+
+	// DefaultProblemTypeBaseURI is prepended to the problem type of goserv errors when no base URI is configured
+	DefaultProblemTypeBaseURI = "urn:goserv:problem:"
+)
+
+// problemTyper is implemented by errors that map to a stable RFC 7807 problem type
+type problemTyper interface {
+	// ProblemType returns the problem type name, relative to the configured problem type base URI
+	ProblemType() string
+}
+
+// ProblemDetails represents an RFC 7807 problem details response body. Extension members are serialized alongside the standard members.
+type ProblemDetails struct {
+	Type       string                 `json:"type" description:"A URI reference identifying the problem type."`
+	Title      string                 `json:"title" description:"A short summary of the problem type."`
+	Status     int                    `json:"status" description:"The status code."`
+	Detail     string                 `json:"detail,omitempty" description:"An explanation specific to this occurrence of the problem."`
+	Instance   string                 `json:"instance,omitempty" description:"A URI reference identifying this occurrence of the problem."`
+	Extensions map[string]interface{} `json:"-"`
+}
+
+// MarshalJSON flattens extension members into the problem details object. Extensions never override the standard members.
+func (p *ProblemDetails) MarshalJSON() ([]byte, error) {
+	members := make(map[string]interface{}, len(p.Extensions)+5)
+	for k, v := range p.Extensions {
+		members[k] = v
+	}
+	members["type"] = p.Type
+	members["title"] = p.Title
+	members["status"] = p.Status
+	if p.Detail != "" {
+		members["detail"] = p.Detail
+	}
+	if p.Instance != "" {
+		members["instance"] = p.Instance
+	}
+	return json.Marshal(members)
+}
+
+// problemType returns the problem type URI for an error. Errors without a problem type map to about:blank, as defined by RFC 7807.
+func problemType(err error, config ErrorConfig) string {
+	var typer problemTyper
+	if !errors.As(err, &typer) {
+		return "about:blank"
+	}
+	base := config.ProblemTypeBaseURI
+	if base == "" {
+		base = DefaultProblemTypeBaseURI
+	}
+	return base + typer.ProblemType()
+}
+
+// acceptsProblemJSON returns true if the Accept header explicitly lists the problem details media type
+func acceptsProblemJSON(accept string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType := strings.TrimSpace(strings.Split(part, ";")[0])
+		if strings.EqualFold(mediaType, MIMEProblemJSON) {
+			return true
+		}
+	}
+	return false
+}
+
+func newProblemDetails(err error, status int, config ErrorConfig) *ProblemDetails {
+	return &ProblemDetails{
+		Type:       problemType(err, config),
+		Title:      http.StatusText(status),
+		Status:     status,
+		Detail:     clientMessage(err, status, config),
+		Extensions: make(map[string]interface{}),
+	}
+}
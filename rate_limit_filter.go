@@ -0,0 +1,265 @@
+// Copyright 2020 Daniel Akiva
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goserv
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/emicklei/go-restful"
+)
+
+const (
+	// RateLimitKeyByIP keys rate limits by client ip address
+	RateLimitKeyByIP = "IP"
+	// RateLimitKeyBySubject keys rate limits by the subject of the authentication token, falling back to the client ip address for anonymous requests
+	RateLimitKeyBySubject = "SUBJECT"
+	// RateLimitKeyByRoute keys rate limits by route, shared by all clients
+	RateLimitKeyByRoute = "ROUTE"
+)
+
+// RateLimitConfig represents configuration for the RateLimitFilter.
+type RateLimitConfig struct {
+	KeyBy          string          `json:"key_by"`
+	TrustedProxies []string        `json:"trusted_proxies"`
+	Rules          []RateLimitRule `json:"rules"`
+}
+
+// RateLimitRule represents a token bucket applied to requests matching a route pattern. Patterns use the URLWhiteList syntax, an empty pattern matches any route while / only matches the root path.
+// The bucket holds Burst tokens (defaulting to Requests) and refills at Requests per Period seconds.
+type RateLimitRule struct {
+	Pattern  string   `json:"pattern"`
+	Methods  []string `json:"methods"`
+	Requests int      `json:"requests"`
+	Period   int      `json:"period"`
+	Burst    int      `json:"burst"`
+}
+
+func (r *RateLimitRule) burst() int {
+	if r.Burst > 0 {
+		return r.Burst
+	}
+	return r.Requests
+}
+
+func (r *RateLimitRule) rate() float64 {
+	return float64(r.Requests) / float64(r.Period)
+}
+
+// Validate ensures the configuration is valid
+func (r *RateLimitConfig) Validate() error {
+	if r.KeyBy != RateLimitKeyByIP &&
+		r.KeyBy != RateLimitKeyBySubject &&
+		r.KeyBy != RateLimitKeyByRoute {
+		return fmt.Errorf("invalid rate limit key %s, must be one of [IP, SUBJECT, ROUTE]", r.KeyBy)
+	}
+	if _, err := parseTrustedProxies(r.TrustedProxies); err != nil {
+		return err
+	}
+	if len(r.Rules) == 0 {
+		return errors.New("no rate limit rules defined")
+	}
+	for _, rule := range r.Rules {
+		if rule.Requests <= 0 || rule.Period <= 0 {
+			return fmt.Errorf("rate limit rule %s requires a positive number of requests and period", rule.Pattern)
+		}
+		if rule.Burst < 0 {
+			return fmt.Errorf("rate limit rule %s burst must not be negative", rule.Pattern)
+		}
+	}
+	return nil
+}
+
+// RateLimitFilter represents a go-restful filter enforcing token bucket rate limits. The first rule matching a request applies, requests matching no rule are not limited.
+// If TokenManager is set, requests keyed by subject that have not passed through the TokenAuthFilter have their token validated by this filter.
+type RateLimitFilter struct {
+	TokenManager   *TokenManager
+	Prefix         string
+	Suffix         string
+	keyBy          string
+	trustedProxies []*net.IPNet
+	rules          []RateLimitRule
+	store          RateLimitStore
+}
+
+// NewRateLimitFilter initializes a new filter instance from a validated configuration. If store is nil, a MemoryRateLimitStore is used.
+func NewRateLimitFilter(config *RateLimitConfig, store RateLimitStore) *RateLimitFilter {
+	if store == nil {
+		store = NewMemoryRateLimitStore()
+	}
+	trustedProxies, _ := parseTrustedProxies(config.TrustedProxies)
+	rules := make([]RateLimitRule, len(config.Rules))
+	for i, rule := range config.Rules {
+		rule.Pattern = normalizeRoutePattern(rule.Pattern)
+		methods := make([]string, len(rule.Methods))
+		for j, m := range rule.Methods {
+			methods[j] = strings.ToLower(m)
+		}
+		rule.Methods = methods
+		rules[i] = rule
+	}
+	return &RateLimitFilter{
+		Prefix:         ":",
+		keyBy:          config.KeyBy,
+		trustedProxies: trustedProxies,
+		rules:          rules,
+		store:          store,
+	}
+}
+
+// Filter fits in the go-restful filterchain, rejecting requests that exceed the rate limit with a 429.
+func (r *RateLimitFilter) Filter(request *restful.Request, response *restful.Response, chain *restful.FilterChain) {
+	rule := r.matchRule(request.Request.URL.Path, request.Request.Method)
+	if rule == nil {
+		chain.ProcessFilter(request, response)
+		return
+	}
+	key := rule.Pattern + "|" + strings.Join(rule.Methods, ",")
+	switch r.keyBy {
+	case RateLimitKeyByIP:
+		key += "|ip:" + r.clientIP(request.Request)
+	case RateLimitKeyBySubject:
+		if subject := r.subject(request); subject != "" {
+			key += "|sub:" + subject
+		} else {
+			key += "|ip:" + r.clientIP(request.Request)
+		}
+	}
+	result, err := r.store.Take(key, rule)
+	if err != nil {
+		// fail open, an unavailable store should not take down the service
+		chain.ProcessFilter(request, response)
+		return
+	}
+	response.AddHeader("RateLimit-Limit", strconv.Itoa(result.Limit))
+	response.AddHeader("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	response.AddHeader("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+	if !result.Allowed {
+		WriteRequestError(request, response, &TooManyRequestsError{RetryAfter: result.RetryAfter, Err: errors.New("rate limit exceeded")})
+		return
+	}
+	chain.ProcessFilter(request, response)
+}
+
+func (r *RateLimitFilter) matchRule(path string, method string) *RateLimitRule {
+	url := normalizeRoutePattern(path)
+	method = strings.ToLower(method)
+	for i := range r.rules {
+		rule := &r.rules[i]
+		if len(rule.Methods) > 0 && !methodMatch(rule.Methods, method) {
+			continue
+		}
+		if rule.Pattern == "" || rule.Pattern == url || segmentsMatch(rule.Pattern, url, r.Prefix, r.Suffix) {
+			return rule
+		}
+	}
+	return nil
+}
+
+// normalizeRoutePattern normalizes a pattern or path like the URLWhiteList, except that the root path remains / rather than the empty pattern matching any route
+func normalizeRoutePattern(pattern string) string {
+	if normalized := normalizeURL(pattern); normalized != "" || pattern == "" {
+		return normalized
+	}
+	return "/"
+}
+
+func (r *RateLimitFilter) subject(request *restful.Request) string {
+	if subject, ok := request.Attribute(TokenSubjectAttribute).(string); ok {
+		return subject
+	}
+	if r.TokenManager == nil {
+		return ""
+	}
+	token, err := parseToken(request.HeaderParameter(authorizationHeader))
+	if err != nil {
+		return ""
+	}
+	subject, err := r.TokenManager.Subject(token)
+	if err != nil {
+		return ""
+	}
+	return subject
+}
+
+func (r *RateLimitFilter) clientIP(request *http.Request) string {
+	return forwardedClientIP(request, r.trustedProxies)
+}
+
+// forwardedClientIP returns the remote address, unless it is a trusted proxy. In that case the X-Forwarded-For chain is walked from the nearest hop until an untrusted address is found.
+func forwardedClientIP(request *http.Request, trustedProxies []*net.IPNet) string {
+	ip := request.RemoteAddr
+	if host, _, err := net.SplitHostPort(ip); err == nil {
+		ip = host
+	}
+	if !trustedProxy(ip, trustedProxies) {
+		return ip
+	}
+	hops := strings.Split(request.Header.Get("X-Forwarded-For"), ",")
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop := strings.TrimSpace(hops[i])
+		if hop == "" {
+			continue
+		}
+		ip = hop
+		if !trustedProxy(hop, trustedProxies) {
+			break
+		}
+	}
+	return ip
+}
+
+func trustedProxy(ip string, trustedProxies []*net.IPNet) bool {
+	parsed := net.ParseIP(ip)
+	if parsed == nil {
+		return false
+	}
+	for _, network := range trustedProxies {
+		if network.Contains(parsed) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTrustedProxies parses a list of CIDR blocks or single ip addresses
+func parseTrustedProxies(proxies []string) ([]*net.IPNet, error) {
+	networks := []*net.IPNet{}
+	for _, proxy := range proxies {
+		if !strings.Contains(proxy, "/") {
+			if ip := net.ParseIP(proxy); ip != nil && ip.To4() != nil {
+				proxy += "/32"
+			} else {
+				proxy += "/128"
+			}
+		}
+		_, network, err := net.ParseCIDR(proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %s: %w", proxy, err)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,171 @@
+// Copyright 2020 Daniel Akiva
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goserv
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/emicklei/go-restful"
+	"github.com/stretchr/testify/assert"
+)
+
+func runRateLimitFilter(filter *RateLimitFilter, req *http.Request) (*httptest.ResponseRecorder, bool) {
+	recorder := httptest.NewRecorder()
+	targetCalled := false
+	chain := &restful.FilterChain{
+		Target: func(*restful.Request, *restful.Response) {
+			targetCalled = true
+		},
+	}
+	filter.Filter(restful.NewRequest(req), restful.NewResponse(recorder), chain)
+	return recorder, targetCalled
+}
+
+func TestRateLimitExceeded(t *testing.T) {
+	// given
+	filter := NewRateLimitFilter(&RateLimitConfig{
+		KeyBy: RateLimitKeyByIP,
+		Rules: []RateLimitRule{{Pattern: "/users/:id", Requests: 2, Period: 60}},
+	}, nil)
+	req := httptest.NewRequest(http.MethodGet, "/users/123", nil)
+
+	// when
+	runRateLimitFilter(filter, req)
+	first, _ := runRateLimitFilter(filter, req)
+	second, targetCalled := runRateLimitFilter(filter, req)
+
+	// then
+	assert.Equal(t, "2", first.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "0", first.Header().Get("RateLimit-Remaining"))
+	assert.False(t, targetCalled)
+	assert.Equal(t, http.StatusTooManyRequests, second.Code)
+	assert.Equal(t, "30", second.Header().Get("Retry-After"))
+}
+
+func TestRateLimitUnmatchedRoute(t *testing.T) {
+	// given
+	filter := NewRateLimitFilter(&RateLimitConfig{
+		KeyBy: RateLimitKeyByRoute,
+		Rules: []RateLimitRule{{Pattern: "/users/:id", Methods: []string{"PUT"}, Requests: 1, Period: 60}},
+	}, nil)
+
+	// when
+	runRateLimitFilter(filter, httptest.NewRequest(http.MethodGet, "/users/123", nil))
+	recorder, targetCalled := runRateLimitFilter(filter, httptest.NewRequest(http.MethodGet, "/users/123", nil))
+
+	// then
+	assert.True(t, targetCalled)
+	assert.Empty(t, recorder.Header().Get("RateLimit-Limit"))
+}
+
+func TestRateLimitRootPattern(t *testing.T) {
+	// given
+	filter := NewRateLimitFilter(&RateLimitConfig{
+		KeyBy: RateLimitKeyByRoute,
+		Rules: []RateLimitRule{{Pattern: "/", Requests: 1, Period: 60}},
+	}, nil)
+
+	// when
+	users, _ := runRateLimitFilter(filter, httptest.NewRequest(http.MethodGet, "/users/123", nil))
+	root, _ := runRateLimitFilter(filter, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	// then
+	assert.Empty(t, users.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", root.Header().Get("RateLimit-Limit"))
+}
+
+func TestRateLimitKeyedByClientBehindTrustedProxy(t *testing.T) {
+	// given
+	filter := NewRateLimitFilter(&RateLimitConfig{
+		KeyBy:          RateLimitKeyByIP,
+		TrustedProxies: []string{"10.0.0.0/8"},
+		Rules:          []RateLimitRule{{Requests: 1, Period: 60}},
+	}, nil)
+	first := httptest.NewRequest(http.MethodGet, "/foo", nil)
+	first.RemoteAddr = "10.0.0.1:1234"
+	first.Header.Set("X-Forwarded-For", "203.0.113.1, 10.0.0.2")
+	second := httptest.NewRequest(http.MethodGet, "/foo", nil)
+	second.RemoteAddr = "10.0.0.1:1234"
+	second.Header.Set("X-Forwarded-For", "203.0.113.2")
+
+	// when
+	_, firstCalled := runRateLimitFilter(filter, first)
+	_, secondCalled := runRateLimitFilter(filter, second)
+
+	// then
+	assert.True(t, firstCalled)
+	assert.True(t, secondCalled)
+}
+
+func TestRateLimitKeyedBySubject(t *testing.T) {
+	// given
+	manager := NewTokenManager([]byte("8831dcf1c522debbdc187f909f52b743f0028777c29517ab12938a624fc4ed12"), 60)
+	filter := NewRateLimitFilter(&RateLimitConfig{
+		KeyBy: RateLimitKeyBySubject,
+		Rules: []RateLimitRule{{Requests: 1, Period: 60}},
+	}, nil)
+	filter.TokenManager = manager
+	token, err := manager.CreateTokenWithSubject("alice")
+	assert.NoError(t, err)
+	anonymous := httptest.NewRequest(http.MethodGet, "/foo", nil)
+	authenticated := httptest.NewRequest(http.MethodGet, "/foo", nil)
+	authenticated.Header.Set(authorizationHeader, "Bearer "+token)
+
+	// when
+	_, anonymousCalled := runRateLimitFilter(filter, anonymous)
+	_, authenticatedCalled := runRateLimitFilter(filter, authenticated)
+	_, repeatCalled := runRateLimitFilter(filter, authenticated)
+
+	// then
+	assert.True(t, anonymousCalled)
+	assert.True(t, authenticatedCalled)
+	assert.False(t, repeatCalled)
+}
+
+func TestMemoryRateLimitStoreRefill(t *testing.T) {
+	// given
+	now := time.Now()
+	store := NewMemoryRateLimitStore()
+	store.now = func() time.Time { return now }
+	rule := &RateLimitRule{Requests: 1, Period: 1}
+	store.Take("key", rule)
+
+	// when
+	denied, _ := store.Take("key", rule)
+	now = now.Add(time.Second)
+	allowed, _ := store.Take("key", rule)
+
+	// then
+	assert.False(t, denied.Allowed)
+	assert.True(t, allowed.Allowed)
+}
+
+func TestInvalidRateLimitConfig(t *testing.T) {
+	// given
+	config := &RateLimitConfig{
+		KeyBy:          RateLimitKeyByIP,
+		TrustedProxies: []string{"not an ip"},
+		Rules:          []RateLimitRule{{Requests: 1, Period: 1}},
+	}
+
+	// when
+	err := config.Validate()
+
+	// then
+	assert.Error(t, err)
+}
@@ -0,0 +1,111 @@
+// Copyright 2020 Daniel Akiva
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goserv
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// RateLimitResult represents the outcome of taking a token from a bucket
+type RateLimitResult struct {
+	// Allowed is true if a token was available
+	Allowed bool
+	// Limit is the bucket capacity
+	Limit int
+	// Remaining is the number of tokens left in the bucket
+	Remaining int
+	// Reset is the duration until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is the duration until the next token is available, zero if the request was allowed
+	RetryAfter time.Duration
+}
+
+// RateLimitStore holds token buckets. Implementations may keep buckets in memory or in a store shared between service instances.
+type RateLimitStore interface {
+	// Take attempts to remove a single token from the bucket identified by the key, creating a full bucket for the rule if none exists.
+	Take(key string, rule *RateLimitRule) (*RateLimitResult, error)
+}
+
+// MemoryRateLimitStore is a RateLimitStore that keeps buckets in process memory. Idle buckets are periodically evicted.
+type MemoryRateLimitStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*tokenBucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// NewMemoryRateLimitStore initializes a new in-memory store
+func NewMemoryRateLimitStore() *MemoryRateLimitStore {
+	return &MemoryRateLimitStore{
+		buckets:   make(map[string]*tokenBucket),
+		lastSweep: time.Now(),
+		now:       time.Now,
+	}
+}
+
+type tokenBucket struct {
+	tokens   float64
+	capacity float64
+	rate     float64
+	updated  time.Time
+}
+
+// Take attempts to remove a single token from the bucket identified by the key.
+func (m *MemoryRateLimitStore) Take(key string, rule *RateLimitRule) (*RateLimitResult, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := m.now()
+	capacity := float64(rule.burst())
+	rate := rule.rate()
+	m.sweep(now)
+	bucket, ok := m.buckets[key]
+	if !ok {
+		bucket = &tokenBucket{tokens: capacity, capacity: capacity, rate: rate, updated: now}
+		m.buckets[key] = bucket
+	} else {
+		bucket.tokens = math.Min(capacity, bucket.tokens+now.Sub(bucket.updated).Seconds()*rate)
+		bucket.updated = now
+	}
+	result := &RateLimitResult{Limit: rule.burst()}
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = secondsToDuration((1 - bucket.tokens) / rate)
+	}
+	result.Remaining = int(bucket.tokens)
+	result.Reset = secondsToDuration((capacity - bucket.tokens) / rate)
+	return result, nil
+}
+
+// sweep evicts buckets that have refilled completely at most once a minute, so that one-off clients do not accumulate.
+func (m *MemoryRateLimitStore) sweep(now time.Time) {
+	if now.Sub(m.lastSweep) < time.Minute {
+		return
+	}
+	m.lastSweep = now
+	for key, bucket := range m.buckets {
+		// a full bucket is indistinguishable from a new one
+		if bucket.tokens+now.Sub(bucket.updated).Seconds()*bucket.rate >= bucket.capacity {
+			delete(m.buckets, key)
+		}
+	}
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
@@ -0,0 +1,97 @@
+// Copyright 2020 Daniel Akiva
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goserv
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"runtime/debug"
+
+	"github.com/emicklei/go-restful"
+	"github.com/op/go-logging"
+)
+
+// RecoveryFilter is middleware that recovers from panics raised further down the filter chain, logging the stack trace and responding with a 500 ErrorBody.
+// It should be installed after the RestfulLoggingFilter so that the trace id is available.
+type RecoveryFilter struct {
+	logger *logging.Logger
+}
+
+// NewRecoveryFilter initializes a new recovery filter instance
+func NewRecoveryFilter(logger *logging.Logger) *RecoveryFilter {
+	return &RecoveryFilter{logger: logger}
+}
+
+// Filter is a filter function that recovers from a panic raised while processing the request
+func (r *RecoveryFilter) Filter(request *restful.Request, response *restful.Response, chain *restful.FilterChain) {
+	writer := &headerTrackingWriter{ResponseWriter: response.ResponseWriter}
+	response.ResponseWriter = writer
+	defer func() {
+		response.ResponseWriter = writer.ResponseWriter
+		if value := recover(); value != nil {
+			if value == http.ErrAbortHandler {
+				// the handler deliberately aborted the response, let net/http handle it
+				panic(value)
+			}
+			panicErr := &PanicError{Value: value, Stack: debug.Stack()}
+			r.logger.Errorf("[panic %s %s] trace=%v %v\n%s", request.Request.Method, request.Request.URL, request.Attribute(TraceIDAttribute), value, panicErr.Stack)
+			if writer.headerWritten {
+				// the response header has already been sent, it is too late to write an error
+				return
+			}
+			WriteRequestError(request, response, panicErr)
+		}
+	}()
+	chain.ProcessFilter(request, response)
+}
+
+// headerTrackingWriter records whether the response header has been sent
+type headerTrackingWriter struct {
+	http.ResponseWriter
+	headerWritten bool
+}
+
+func (w *headerTrackingWriter) WriteHeader(status int) {
+	w.headerWritten = true
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *headerTrackingWriter) Write(b []byte) (int, error) {
+	w.headerWritten = true
+	return w.ResponseWriter.Write(b)
+}
+
+// Flush flushes the wrapped writer, if it supports flushing
+func (w *headerTrackingWriter) Flush() {
+	if flusher, ok := w.ResponseWriter.(http.Flusher); ok {
+		w.headerWritten = true
+		flusher.Flush()
+	}
+}
+
+// CloseNotify is part of http.CloseNotifier interface, required by restful.Response
+func (w *headerTrackingWriter) CloseNotify() <-chan bool {
+	return w.ResponseWriter.(http.CloseNotifier).CloseNotify()
+}
+
+// Hijack implements http.Hijacker if the underlying writer supports it
+func (w *headerTrackingWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if hijacker, ok := w.ResponseWriter.(http.Hijacker); ok {
+		return hijacker.Hijack()
+	}
+	return nil, nil, errors.New("http.Hijacker not implemented by underlying http.ResponseWriter")
+}
@@ -0,0 +1,89 @@
+// Copyright 2020 Daniel Akiva
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goserv
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/emicklei/go-restful"
+	"github.com/op/go-logging"
+	"github.com/stretchr/testify/assert"
+)
+
+func newPanickingContainer() *restful.Container {
+	return newRecoveryContainer(func(*restful.Request, *restful.Response) {
+		panic("boom")
+	})
+}
+
+func newRecoveryContainer(handler restful.RouteFunction) *restful.Container {
+	container := restful.NewContainer()
+	container.Filter(NewRecoveryFilter(logging.MustGetLogger("test")).Filter)
+	ws := new(restful.WebService)
+	ws.Route(ws.GET("/foo").To(handler))
+	container.Add(ws)
+	return container
+}
+
+func TestRecoverPanic(t *testing.T) {
+	// given
+	container := newPanickingContainer()
+	recorder := httptest.NewRecorder()
+
+	// when
+	container.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/foo", nil))
+
+	// then
+	assert.Equal(t, http.StatusInternalServerError, recorder.Code)
+	body := &ErrorBody{}
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), body))
+	assert.Equal(t, http.StatusInternalServerError, body.StatusCode)
+	assert.Empty(t, body.StackTrace)
+}
+
+func TestRecoverPanicWithStackTrace(t *testing.T) {
+	// given
+	(&ErrorConfig{IncludeStackTrace: true}).InitializeErrors(nil)
+	defer (&ErrorConfig{}).InitializeErrors(nil)
+	container := newPanickingContainer()
+	recorder := httptest.NewRecorder()
+
+	// when
+	container.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/foo", nil))
+
+	// then
+	body := &ErrorBody{}
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), body))
+	assert.Contains(t, body.StackTrace, "recovery_filter_test.go")
+}
+
+func TestRecoverPanicAfterHeaderWritten(t *testing.T) {
+	// given
+	container := newRecoveryContainer(func(request *restful.Request, response *restful.Response) {
+		response.WriteHeader(http.StatusAccepted)
+		panic("boom")
+	})
+	recorder := httptest.NewRecorder()
+
+	// when
+	container.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/foo", nil))
+
+	// then
+	assert.Equal(t, http.StatusAccepted, recorder.Code)
+	assert.Empty(t, recorder.Body.String())
+}
@@ -15,19 +15,73 @@
 package goserv
 
 import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
 	"github.com/emicklei/go-restful"
 )
 
 // RequestBody represents a request body that can be validated.
 type RequestBody interface {
-	// Validate validatess the request body, returning an error if validation fails.
+	// Validate validatess the request body, returning an error if validation fails. A ValidationBuilder can be used to report every invalid field at once.
 	Validate() error
 }
 
 // ExtractRequestBody extracts  the body of a request into a RequestBody and validates it. Returns an error if the extraction fails.
+// The body is first validated against its validate struct tags (see ValidateStruct), then by its Validate function.
+// A value of the wrong type is reported as an IllegalArgumentError wrapping a ValidationError that points at the offending field.
+// The body is decoded according to the RequestBodyConfig installed by InitializeRequestBodies, if any.
 func ExtractRequestBody(request *restful.Request, body RequestBody) error {
-	if err := request.ReadEntity(body); err != nil {
-		return &IllegalArgumentError{Err: err}
+	return ExtractRequestBodyWithConfig(request, body, currentRequestBodyConfig())
+}
+
+// ExtractRequestBodyWithConfig behaves like ExtractRequestBody, decoding the body according to a route specific configuration. A nil configuration decodes leniently.
+// Unknown fields, duplicate keys and excessive nesting are reported as an IllegalArgumentError pointing at the offending member, an oversized body as a PayloadTooLargeError.
+func ExtractRequestBodyWithConfig(request *restful.Request, body RequestBody, config *RequestBodyConfig) error {
+	if err := readEntity(request, body, config); err != nil {
+		var illegalArgErr *IllegalArgumentError
+		var tooLargeErr *PayloadTooLargeError
+		if errors.As(err, &illegalArgErr) || errors.As(err, &tooLargeErr) {
+			return err
+		}
+		return decodeError(err)
+	}
+	if err := ValidateStruct(body); err != nil {
+		return err
 	}
 	return body.Validate()
 }
+
+func readEntity(request *restful.Request, body RequestBody, config *RequestBodyConfig) error {
+	if config.isStrict() {
+		return config.readStrictEntity(request, body)
+	}
+	return request.ReadEntity(body)
+}
+
+// decodeError converts a JSON decoding error into an IllegalArgumentError. A value of the wrong type is reported with a ValidationError that points at the offending field.
+func decodeError(err error) error {
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) && typeErr.Field != "" {
+		path := JSONPointer(toPointerTokens(typeErr.Field)...)
+		return &IllegalArgumentError{
+			Argument: path,
+			Err: NewValidationBuilder().
+				Add(path, "type", fmt.Sprintf("expected %v but got %v", typeErr.Type, typeErr.Value)).
+				Error(),
+		}
+	}
+	return &IllegalArgumentError{Err: err}
+}
+
+// toPointerTokens splits a dotted field path as reported by encoding/json into JSON pointer reference tokens
+func toPointerTokens(field string) []interface{} {
+	parts := strings.Split(field, ".")
+	tokens := make([]interface{}, len(parts))
+	for i, part := range parts {
+		tokens[i] = part
+	}
+	return tokens
+}
@@ -0,0 +1,249 @@
+// Copyright 2020 Daniel Akiva
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goserv
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"reflect"
+	"strings"
+	"sync"
+
+	"github.com/emicklei/go-restful"
+)
+
+var (
+	requestBodyConfigLock sync.RWMutex
+	requestBodyConfig     *RequestBodyConfig
+)
+
+// RequestBodyConfig controls how strictly JSON request bodies are decoded by ExtractRequestBody. The zero value decodes bodies as leniently as restful.Request.ReadEntity.
+type RequestBodyConfig struct {
+	// DisallowUnknownFields rejects object members that do not map to a field of the request body
+	DisallowUnknownFields bool `json:"disallow_unknown_fields"`
+	// DisallowDuplicateKeys rejects objects that repeat a member name
+	DisallowDuplicateKeys bool `json:"disallow_duplicate_keys"`
+	// UseNumber decodes numbers into interface{} values as a json.Number rather than a float64
+	UseNumber bool `json:"use_number"`
+	// MaxBodySize is the maximum size of a request body in bytes, 0 for unlimited
+	MaxBodySize int64 `json:"max_body_size"`
+	// MaxDepth is the maximum nesting depth of objects and arrays, 0 for unlimited
+	MaxDepth int `json:"max_depth"`
+}
+
+// Validate ensures the configuration is valid
+func (r *RequestBodyConfig) Validate() error {
+	if r.MaxBodySize < 0 {
+		return errors.New("max body size must not be negative")
+	}
+	if r.MaxDepth < 0 {
+		return errors.New("max depth must not be negative")
+	}
+	return nil
+}
+
+// InitializeRequestBodies installs this configuration for all subsequent ExtractRequestBody calls.
+func (r *RequestBodyConfig) InitializeRequestBodies() {
+	requestBodyConfigLock.Lock()
+	defer requestBodyConfigLock.Unlock()
+	config := *r
+	requestBodyConfig = &config
+}
+
+func currentRequestBodyConfig() *RequestBodyConfig {
+	requestBodyConfigLock.RLock()
+	defer requestBodyConfigLock.RUnlock()
+	return requestBodyConfig
+}
+
+// isStrict returns true if the configuration requires decoding beyond restful.Request.ReadEntity
+func (r *RequestBodyConfig) isStrict() bool {
+	return r != nil && *r != RequestBodyConfig{}
+}
+
+// readBody reads a request body, returning a PayloadTooLargeError if it exceeds the max body size. A nil configuration reads the whole body.
+func (r *RequestBodyConfig) readBody(request *http.Request) ([]byte, error) {
+	var reader io.Reader = request.Body
+	if r != nil && r.MaxBodySize > 0 {
+		reader = io.LimitReader(reader, r.MaxBodySize+1)
+	}
+	data, err := ioutil.ReadAll(reader)
+	if err != nil {
+		return nil, &IllegalArgumentError{Err: err}
+	}
+	if r != nil && r.MaxBodySize > 0 && int64(len(data)) > r.MaxBodySize {
+		return nil, &PayloadTooLargeError{MaxBytes: r.MaxBodySize}
+	}
+	return data, nil
+}
+
+// readStrictEntity reads a request body according to the configuration. Bodies that are not JSON are only subject to the size limit.
+func (r *RequestBodyConfig) readStrictEntity(request *restful.Request, entityPointer interface{}) error {
+	data, err := r.readBody(request.Request)
+	if err != nil {
+		return err
+	}
+	if !strings.Contains(strings.ToLower(request.HeaderParameter("Content-Type")), "json") {
+		request.Request.Body = ioutil.NopCloser(bytes.NewReader(data))
+		return request.ReadEntity(entityPointer)
+	}
+	if r.DisallowUnknownFields || r.DisallowDuplicateKeys || r.MaxDepth > 0 {
+		checker := &jsonStructureChecker{decoder: json.NewDecoder(bytes.NewReader(data)), config: r}
+		if err := checker.checkValue(reflect.TypeOf(entityPointer), nil, 0); err != nil {
+			return err
+		}
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	if r.DisallowUnknownFields {
+		decoder.DisallowUnknownFields()
+	}
+	if r.UseNumber {
+		decoder.UseNumber()
+	}
+	return decoder.Decode(entityPointer)
+}
+
+// jsonStructureChecker walks the tokens of a JSON document alongside the Go type it decodes into, reporting unknown fields, duplicate keys and excessive nesting with the JSON pointer of the offending member.
+type jsonStructureChecker struct {
+	decoder *json.Decoder
+	config  *RequestBodyConfig
+}
+
+func (j *jsonStructureChecker) checkValue(t reflect.Type, path []interface{}, depth int) error {
+	token, err := j.decoder.Token()
+	if err != nil {
+		return &IllegalArgumentError{Err: err}
+	}
+	delim, ok := token.(json.Delim)
+	if !ok {
+		return nil
+	}
+	depth++
+	if j.config.MaxDepth > 0 && depth > j.config.MaxDepth {
+		return j.violation(path, "depth", fmt.Sprintf("nesting exceeds the maximum depth of %v", j.config.MaxDepth))
+	}
+	t = decodedType(t)
+	if delim == '[' {
+		var elemType reflect.Type
+		if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+			elemType = t.Elem()
+		}
+		for i := 0; j.decoder.More(); i++ {
+			if err := j.checkValue(elemType, append(path, i), depth); err != nil {
+				return err
+			}
+		}
+	} else {
+		seen := map[string]bool{}
+		for j.decoder.More() {
+			token, err := j.decoder.Token()
+			if err != nil {
+				return &IllegalArgumentError{Err: err}
+			}
+			key, _ := token.(string)
+			memberPath := append(path, key)
+			if j.config.DisallowDuplicateKeys && seen[key] {
+				return j.violation(memberPath, "duplicate", fmt.Sprintf("duplicate key %v", key))
+			}
+			seen[key] = true
+			memberType, known := jsonMemberType(t, key)
+			if !known && j.config.DisallowUnknownFields {
+				return j.violation(memberPath, "unknown", fmt.Sprintf("unknown field %v", key))
+			}
+			if err := j.checkValue(memberType, memberPath, depth); err != nil {
+				return err
+			}
+		}
+	}
+	// consume the closing delimiter
+	if _, err := j.decoder.Token(); err != nil {
+		return &IllegalArgumentError{Err: err}
+	}
+	return nil
+}
+
+func (j *jsonStructureChecker) violation(path []interface{}, rule string, message string) error {
+	pointer := JSONPointer(path...)
+	return &IllegalArgumentError{
+		Argument: pointer,
+		Err:      NewValidationBuilder().Add(pathOrRoot(pointer), rule, message).Error(),
+	}
+}
+
+var jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// decodedType dereferences pointers, returning nil for types whose members cannot be checked, ie interfaces and custom unmarshalers
+func decodedType(t reflect.Type) reflect.Type {
+	for t != nil && t.Kind() == reflect.Ptr {
+		if t.Implements(jsonUnmarshalerType) {
+			return nil
+		}
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() == reflect.Interface || reflect.PtrTo(t).Implements(jsonUnmarshalerType) {
+		return nil
+	}
+	return t
+}
+
+// jsonMemberType returns the type an object member decodes into, and whether the member is known. Members of unchecked types are always known.
+func jsonMemberType(t reflect.Type, key string) (reflect.Type, bool) {
+	if t == nil {
+		return nil, true
+	}
+	switch t.Kind() {
+	case reflect.Map:
+		return t.Elem(), true
+	case reflect.Struct:
+		return jsonStructFieldType(t, key)
+	}
+	// a type mismatch is reported by the decoder
+	return nil, true
+}
+
+// jsonStructFieldType finds the field a member decodes into, matching names case insensitively and promoting the fields of embedded structs as encoding/json does
+func jsonStructFieldType(t reflect.Type, key string) (reflect.Type, bool) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		name, skip := jsonFieldName(f)
+		if skip {
+			continue
+		}
+		if f.Anonymous && f.Tag.Get("json") == "" {
+			embedded := f.Type
+			if embedded.Kind() == reflect.Ptr {
+				embedded = embedded.Elem()
+			}
+			if embedded.Kind() == reflect.Struct {
+				if fieldType, ok := jsonStructFieldType(embedded, key); ok {
+					return fieldType, true
+				}
+				continue
+			}
+		}
+		if f.PkgPath != "" {
+			continue
+		}
+		if strings.EqualFold(name, key) {
+			return f.Type, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,194 @@
+// Copyright 2020 Daniel Akiva
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goserv
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/emicklei/go-restful"
+	"github.com/stretchr/testify/assert"
+)
+
+type testAddress struct {
+	City string `json:"city"`
+	Zip  string `json:"zip"`
+}
+
+type testUserBody struct {
+	Name    string      `json:"name"`
+	Email   string      `json:"email"`
+	Age     int         `json:"age"`
+	Address testAddress `json:"address"`
+}
+
+func (u *testUserBody) Validate() error {
+	return NewValidationBuilder().
+		Check(u.Name != "", "/name", "required", "name is required").
+		Check(strings.Contains(u.Email, "@"), "/email", "email", "email must be a valid email address").
+		Check(u.Address.Zip != "", JSONPointer("address", "zip"), "required", "zip is required").
+		Error()
+}
+
+func newJSONRequest(body string) *restful.Request {
+	httpReq := httptest.NewRequest(http.MethodPost, "/users", strings.NewReader(body))
+	httpReq.Header.Set("Content-Type", restful.MIME_JSON)
+	return restful.NewRequest(httpReq)
+}
+
+func TestExtractRequestBodyAccumulatesFieldErrors(t *testing.T) {
+	// given
+	request := newJSONRequest(`{"email":"foo","address":{"city":"Boston"}}`)
+	body := &testUserBody{}
+
+	// when
+	err := ExtractRequestBody(request, body)
+
+	// then
+	validationErr := &ValidationError{}
+	assert.True(t, errors.As(err, &validationErr))
+	assert.Equal(t, []FieldError{
+		{Path: "/name", Rule: "required", Message: "name is required"},
+		{Path: "/email", Rule: "email", Message: "email must be a valid email address"},
+		{Path: "/address/zip", Rule: "required", Message: "zip is required"},
+	}, validationErr.FieldErrors)
+}
+
+func TestExtractRequestBodyTypeError(t *testing.T) {
+	// given
+	request := newJSONRequest(`{"name":"bob","age":"old"}`)
+	body := &testUserBody{}
+
+	// when
+	err := ExtractRequestBody(request, body)
+
+	// then
+	illegalArgErr := &IllegalArgumentError{}
+	assert.True(t, errors.As(err, &illegalArgErr))
+	assert.Equal(t, "/age", illegalArgErr.Argument)
+	validationErr := &ValidationError{}
+	assert.True(t, errors.As(err, &validationErr))
+	assert.Equal(t, "type", validationErr.FieldErrors[0].Rule)
+}
+
+func TestWriteValidationError(t *testing.T) {
+	// given
+	recorder := httptest.NewRecorder()
+	err := NewValidationBuilder().
+		Add("/name", "required", "name is required").
+		Add("/items/0/qty", "min", "qty must be at least 1").
+		Error()
+
+	// when
+	WriteError(restful.NewResponse(recorder), err)
+
+	// then
+	assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	body := &ErrorBody{}
+	assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), body))
+	assert.Equal(t, ErrorCodeValidationFailed, body.Code)
+	assert.Len(t, body.FieldErrors, 2)
+}
+
+func TestValidationBuilderMerge(t *testing.T) {
+	// given
+	nested := NewValidationBuilder().Add("/zip", "required", "zip is required").Error()
+
+	// when
+	err := NewValidationBuilder().Merge("/address", nested).Merge("/other", nil).Error()
+
+	// then
+	assert.Equal(t, "/address/zip", err.(*ValidationError).FieldErrors[0].Path)
+}
+
+func TestJSONPointerEscaping(t *testing.T) {
+	assert.Equal(t, "/a~1b/m~0n/2", JSONPointer("a/b", "m~n", 2))
+}
+
+func TestExtractRequestBodyUnknownField(t *testing.T) {
+	// given
+	request := newJSONRequest(`{"name":"bob","email":"bob@example.com","address":{"zip":"02110","street":"Main"}}`)
+	config := &RequestBodyConfig{DisallowUnknownFields: true}
+
+	// when
+	err := ExtractRequestBodyWithConfig(request, &testUserBody{}, config)
+
+	// then
+	illegalArgErr := &IllegalArgumentError{}
+	assert.True(t, errors.As(err, &illegalArgErr))
+	assert.Equal(t, "/address/street", illegalArgErr.Argument)
+}
+
+func TestExtractRequestBodyDuplicateKey(t *testing.T) {
+	// given
+	request := newJSONRequest(`{"name":"bob","email":"bob@example.com","name":"alice"}`)
+	config := &RequestBodyConfig{DisallowDuplicateKeys: true}
+
+	// when
+	err := ExtractRequestBodyWithConfig(request, &testUserBody{}, config)
+
+	// then
+	validationErr := &ValidationError{}
+	assert.True(t, errors.As(err, &validationErr))
+	assert.Equal(t, FieldError{Path: "/name", Rule: "duplicate", Message: "duplicate key name"}, validationErr.FieldErrors[0])
+}
+
+func TestExtractRequestBodyMaxDepth(t *testing.T) {
+	// given
+	request := newJSONRequest(`{"name":"bob","email":"bob@example.com","address":{"zip":"02110"}}`)
+	config := &RequestBodyConfig{MaxDepth: 1}
+
+	// when
+	err := ExtractRequestBodyWithConfig(request, &testUserBody{}, config)
+
+	// then
+	illegalArgErr := &IllegalArgumentError{}
+	assert.True(t, errors.As(err, &illegalArgErr))
+	assert.Equal(t, "/address", illegalArgErr.Argument)
+}
+
+func TestExtractRequestBodyMaxBodySize(t *testing.T) {
+	// given
+	request := newJSONRequest(`{"name":"bob","email":"bob@example.com","address":{"zip":"02110"}}`)
+	config := &RequestBodyConfig{MaxBodySize: 16}
+
+	// when
+	err := ExtractRequestBodyWithConfig(request, &testUserBody{}, config)
+
+	// then
+	assert.IsType(t, &PayloadTooLargeError{}, err)
+}
+
+func TestExtractRequestBodyGlobalConfig(t *testing.T) {
+	// given
+	(&RequestBodyConfig{UseNumber: true, DisallowUnknownFields: true}).InitializeRequestBodies()
+	defer (&RequestBodyConfig{}).InitializeRequestBodies()
+	request := newJSONRequest(`{"name":"bob","email":"bob@example.com","address":{"zip":"02110"},"extra":{"count":12345678901234567890}}`)
+	body := &struct {
+		testUserBody
+		Extra map[string]interface{} `json:"extra"`
+	}{}
+
+	// when
+	err := ExtractRequestBody(request, body)
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, json.Number("12345678901234567890"), body.Extra["count"])
+}
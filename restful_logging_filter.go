@@ -21,7 +21,6 @@ import (
 	"time"
 
 	"github.com/emicklei/go-restful"
-	"github.com/google/uuid"
 	"github.com/op/go-logging"
 )
 
@@ -30,10 +29,14 @@ const (
 	DefaultRequestLogFormat = "[Request %s %s] trace=%s content_length=%d form=%s headers=[%s]\n"
 	// DefaultResponseLogFormat format
 	DefaultResponseLogFormat = "[Response %s %s] trace=%s status=%d time=%s headers=[%s]\n"
+	// DefaultRequestBodyLogFormat format
+	DefaultRequestBodyLogFormat = "[Request body %s %s] trace=%s body=%s\n"
+	// DefaultResponseBodyLogFormat format
+	DefaultResponseBodyLogFormat = "[Response body %s %s] trace=%s status=%d body=%s\n"
 
 	// RequestTimestampAttribute is an attribute representing the timestamp at which the request was received by the service
 	RequestTimestampAttribute = "request_timestamp_attr"
-	// TraceIDAttribute is an attribute representing a unique identifier for the request useful for correlating log statements
+	// TraceIDAttribute is an attribute representing the W3C trace id of the request useful for correlating log statements, see TraceContext
 	TraceIDAttribute = "trace_id_attr"
 )
 
@@ -43,6 +46,8 @@ type RestfulLoggingFilter struct {
 	requestLogFormat  string
 	responseLogFormat string
 	logRoot           bool
+	bodyLogging       *BodyLoggingConfig
+	container         *restful.Container
 }
 
 // NewRestfulLoggingFilter initializes a new logging filter instance
@@ -65,37 +70,109 @@ func (r *RestfulLoggingFilter) SetResponseLogFormat(format string) {
 	r.responseLogFormat = format
 }
 
+// SetBodyLogging sets the default body logging and redaction configuration, which routes may override using LogBodies
+func (r *RestfulLoggingFilter) SetBodyLogging(config *BodyLoggingConfig) {
+	r.bodyLogging = config
+}
+
+// SetContainer sets the container whose routes may override the body logging configuration, defaulting to the restful.DefaultContainer
+func (r *RestfulLoggingFilter) SetContainer(container *restful.Container) {
+	r.container = container
+}
+
 // SetLogRoot toggles logging the root call (ping)
 func (r *RestfulLoggingFilter) SetLogRoot(logRoot bool) {
 	r.logRoot = logRoot
 }
 
-// Filter is a filter function that logs before and after processing the request
+// Filter is a filter function that logs before and after processing the request.
+// The trace context of the request is continued from its traceparent header or started anew, stored in the request context, see TraceFromContext, and its trace id is echoed in the X-Trace-Id response header.
 func (r *RestfulLoggingFilter) Filter(request *restful.Request, response *restful.Response, chain *restful.FilterChain) {
 	start := time.Now()
 	request.SetAttribute(RequestTimestampAttribute, start)
 
-	traceID, err := uuid.NewRandom()
-	if err != nil {
-		request.SetAttribute(TraceIDAttribute, traceID)
-	}
+	trace := TraceContextFromRequest(request.Request)
+	traceID := trace.TraceID
+	request.SetAttribute(TraceIDAttribute, traceID)
+	request.Request = request.Request.WithContext(ContextWithTrace(request.Request.Context(), trace))
+	response.AddHeader(TraceIDResponseHeader, traceID)
 	if !r.logRoot && isRoot(request) {
 		// don't log the root call, as it is typically used as a server ping and can clutter the log
 		chain.ProcessFilter(request, response)
 		return
 	}
+	config := r.bodyLogging
+	if routeConfig, ok := r.routeBodyLogging(request.Request); ok {
+		config = routeConfig
+	}
+	if config == nil {
+		config = &BodyLoggingConfig{}
+	}
 	if r.logger.IsEnabledFor(logging.DEBUG) {
-		headerStr := flattenHeader(request.Request.Header)
-		r.logger.Debugf(r.requestLogFormat, request.Request.Method, request.Request.URL, traceID, request.Request.ContentLength, request.Request.Form, headerStr)
+		headerStr := flattenHeader(request.Request.Header, config.RedactHeaders)
+		fields := r.requestFields(request, trace)
+		fields["content_length"] = request.Request.ContentLength
+		logWithFields(r.logger, logging.DEBUG, fields, r.requestLogFormat, request.Request.Method, redactURL(request.Request.URL, config.RedactQueryParams), traceID, request.Request.ContentLength, redactValues(request.Request.Form, config.RedactQueryParams), headerStr)
+	}
+	var capture *bodyCapture
+	if config.LogRequestBody || config.LogResponseBody {
+		capture = captureBodies(request, response, config)
+		defer capture.restore(response)
 	}
 
 	chain.ProcessFilter(request, response)
-	if response.StatusCode() >= 400 && r.logger.IsEnabledFor(logging.ERROR) {
-		headerStr := flattenHeader(response.Header())
-		r.logger.Errorf(r.responseLogFormat, request.Request.Method, request.Request.URL, traceID, response.StatusCode(), time.Now().Sub(start), headerStr)
-	} else if r.logger.IsEnabledFor(logging.DEBUG) {
-		headerStr := flattenHeader(response.Header())
-		r.logger.Debugf(r.responseLogFormat, request.Request.Method, request.Request.URL, traceID, response.StatusCode(), time.Now().Sub(start), headerStr)
+	level := logging.DEBUG
+	if response.StatusCode() >= 400 {
+		level = logging.ERROR
+	}
+	if !r.logger.IsEnabledFor(level) {
+		return
+	}
+	url := redactURL(request.Request.URL, config.RedactQueryParams)
+	duration := time.Now().Sub(start)
+	headerStr := flattenHeader(response.Header(), config.RedactHeaders)
+	fields := r.requestFields(request, trace)
+	fields["status"] = response.StatusCode()
+	fields["duration_ms"] = durationMillis(duration)
+	logWithFields(r.logger, level, fields, r.responseLogFormat, request.Request.Method, url, traceID, response.StatusCode(), duration, headerStr)
+	if capture == nil {
+		return
+	}
+	if body, ok := capture.loggedRequestBody(request); ok {
+		fields := r.requestFields(request, trace)
+		fields["request_body"] = body
+		logWithFields(r.logger, level, fields, DefaultRequestBodyLogFormat, request.Request.Method, url, traceID, body)
+	}
+	if body, ok := capture.loggedResponseBody(response); ok {
+		fields := r.requestFields(request, trace)
+		fields["status"] = response.StatusCode()
+		fields["response_body"] = body
+		logWithFields(r.logger, level, fields, DefaultResponseBodyLogFormat, request.Request.Method, url, traceID, response.StatusCode(), body)
+	}
+}
+
+// routeBodyLogging returns the body logging configuration of the route of a request, or false if the route does not override the configuration, see LogBodies.
+// Routes are selected using the CurlyRouter, the default router of go-restful containers.
+func (r *RestfulLoggingFilter) routeBodyLogging(request *http.Request) (*BodyLoggingConfig, bool) {
+	container := r.container
+	if container == nil {
+		container = restful.DefaultContainer
+	}
+	_, route, err := restful.CurlyRouter{}.SelectRoute(container.RegisteredWebServices(), request)
+	if err != nil {
+		return nil, false
+	}
+	config, ok := route.Metadata[BodyLoggingMetadata].(*BodyLoggingConfig)
+	return config, ok
+}
+
+// requestFields returns the structured logging fields identifying a request
+func (r *RestfulLoggingFilter) requestFields(request *restful.Request, trace *TraceContext) Fields {
+	return Fields{
+		"trace_id": trace.TraceID,
+		"span_id":  trace.SpanID,
+		"method":   request.Request.Method,
+		"path":     request.Request.URL.Path,
 	}
 }
 
@@ -103,11 +180,12 @@ func isRoot(request *restful.Request) bool {
 	return request.Request.Method == "GET" && request.Request.URL != nil && request.Request.URL.String() == "/"
 }
 
-func flattenHeader(h http.Header) string {
+// flattenHeader renders a header, hiding the values of the default redacted headers and of additional redacted headers
+func flattenHeader(h http.Header, redacted []string) string {
 	headerStr := ""
 	for k, v := range h {
 		val := ""
-		if strings.EqualFold(k, authorizationHeader) {
+		if redactedHeader(k, redacted) {
 			val = "[hidden]"
 		} else {
 			val = flatten(v)
@@ -120,6 +198,17 @@ func flattenHeader(h http.Header) string {
 	return headerStr
 }
 
+func redactedHeader(name string, redacted []string) bool {
+	for _, headers := range [][]string{DefaultRedactedHeaders, redacted} {
+		for _, header := range headers {
+			if strings.EqualFold(name, header) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func flatten(value []string) string {
 	str := ""
 	for _, v := range value {
@@ -0,0 +1,345 @@
+// Copyright 2020 Daniel Akiva
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goserv
+
+import (
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+const (
+	rotatedFileTimeFormat = "20060102T150405.000"
+	compressedFileSuffix  = ".gz"
+)
+
+var (
+	sighupOnce    sync.Once
+	sighupLock    sync.Mutex
+	sighupWriters []*RotatingFileWriter
+)
+
+// FileRotationConfig represents rotation settings of a FILE logging backend. Files are rotated when they exceed MaxSize bytes and/or every RotationInterval seconds.
+type FileRotationConfig struct {
+	// MaxSize is the size in bytes at which the file is rotated, 0 disables size based rotation
+	MaxSize int64 `json:"max_size"`
+	// RotationInterval is the number of seconds after which the file is rotated, 0 disables time based rotation
+	RotationInterval int `json:"rotation_interval"`
+	// MaxBackups is the number of rotated files to retain, 0 retains all
+	MaxBackups int `json:"max_backups"`
+	// MaxAge is the number of seconds to retain rotated files, 0 retains them regardless of age
+	MaxAge int `json:"max_age"`
+	// Compress gzips rotated files
+	Compress bool `json:"compress"`
+	// ReopenOnSIGHUP reopens the file when the process receives SIGHUP, to cooperate with an external logrotate
+	ReopenOnSIGHUP bool `json:"reopen_on_sighup"`
+}
+
+// Validate ensures the configuration is valid
+func (f *FileRotationConfig) Validate() error {
+	if f.MaxSize < 0 {
+		return errors.New("max size must not be negative")
+	}
+	if f.RotationInterval < 0 {
+		return errors.New("rotation interval must not be negative")
+	}
+	if f.MaxBackups < 0 {
+		return errors.New("max backups must not be negative")
+	}
+	if f.MaxAge < 0 {
+		return errors.New("max age must not be negative")
+	}
+	return nil
+}
+
+// RotatingFileWriter is an io.Writer appending to a file that is rotated by size and/or time. Rotated files are renamed with a UTC timestamp, ie app-20200102T150405.000.log, optionally compressed, and pruned by count and age.
+type RotatingFileWriter struct {
+	path     string
+	config   FileRotationConfig
+	lock     sync.Mutex
+	file     *os.File
+	size     int64
+	openedAt time.Time
+	pending  sync.WaitGroup
+	// maintenance serializes compression and pruning of rotated files
+	maintenance sync.Mutex
+	now         func() time.Time
+}
+
+// NewRotatingFileWriter opens a file for appending, rotating it according to the configuration
+func NewRotatingFileWriter(path string, config *FileRotationConfig) (*RotatingFileWriter, error) {
+	writer := &RotatingFileWriter{path: path, config: *config, now: time.Now}
+	if err := writer.open(); err != nil {
+		return nil, err
+	}
+	if config.ReopenOnSIGHUP {
+		reopenOnSIGHUP(writer)
+	}
+	return writer, nil
+}
+
+// Write appends to the file, rotating it first if the write would exceed the max size or the rotation interval has elapsed.
+// If the rotation fails, p is still appended to the current file and the rotation error is returned.
+func (r *RotatingFileWriter) Write(p []byte) (int, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.file == nil {
+		return 0, os.ErrClosed
+	}
+	var rotateErr error
+	if r.shouldRotate(int64(len(p))) {
+		rotateErr = r.rotate()
+	}
+	n, err := r.file.Write(p)
+	r.size += int64(n)
+	if err != nil {
+		return n, err
+	}
+	return n, rotateErr
+}
+
+// Rotate rotates the file immediately. If the rotation fails, writes continue to the current file.
+func (r *RotatingFileWriter) Rotate() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.file == nil {
+		return os.ErrClosed
+	}
+	return r.rotate()
+}
+
+// Reopen reopens the file at the configured path, picking up a file that was moved by an external tool. If the file cannot be opened, writes continue to the current file.
+func (r *RotatingFileWriter) Reopen() error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.file == nil {
+		return os.ErrClosed
+	}
+	previous := r.file
+	if err := r.open(); err != nil {
+		return err
+	}
+	previous.Close()
+	return nil
+}
+
+// Close closes the file, waiting for pending compression and pruning of rotated files
+func (r *RotatingFileWriter) Close() error {
+	unregisterSIGHUP(r)
+	r.lock.Lock()
+	var err error
+	if r.file != nil {
+		err = r.file.Close()
+		r.file = nil
+	}
+	r.lock.Unlock()
+	r.pending.Wait()
+	return err
+}
+
+func (r *RotatingFileWriter) open() error {
+	file, err := os.OpenFile(r.path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0666)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	r.file = file
+	r.size = info.Size()
+	r.openedAt = r.now()
+	return nil
+}
+
+func (r *RotatingFileWriter) shouldRotate(writeSize int64) bool {
+	if r.config.MaxSize > 0 && r.size > 0 && r.size+writeSize > r.config.MaxSize {
+		return true
+	}
+	interval := time.Duration(r.config.RotationInterval) * time.Second
+	return interval > 0 && !r.now().Before(r.openedAt.Add(interval))
+}
+
+// rotate renames the current file and opens a new one, swapping the files only once the new one is open
+func (r *RotatingFileWriter) rotate() error {
+	now := r.now()
+	rotated := r.rotatedName(now)
+	if err := os.Rename(r.path, rotated); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	previous := r.file
+	if err := r.open(); err != nil {
+		// keep writing to the current file, moving it back to the configured path
+		os.Rename(rotated, r.path)
+		return err
+	}
+	previous.Close()
+	r.pending.Add(1)
+	go func() {
+		defer r.pending.Done()
+		r.maintenance.Lock()
+		defer r.maintenance.Unlock()
+		if r.config.Compress {
+			compressFile(rotated)
+		}
+		r.prune(now)
+	}()
+	return nil
+}
+
+// rotatedName returns the name of a rotated file, inserting the UTC timestamp between the base name and extension. A counter is appended to the timestamp if a file rotated at the same time exists, ie app-20200102T150405.000-1.log.
+func (r *RotatingFileWriter) rotatedName(t time.Time) string {
+	ext := filepath.Ext(r.path)
+	stamp := fmt.Sprintf("%s-%s", strings.TrimSuffix(r.path, ext), t.UTC().Format(rotatedFileTimeFormat))
+	name := stamp + ext
+	for i := 1; fileExists(name) || fileExists(name+compressedFileSuffix); i++ {
+		name = fmt.Sprintf("%s-%d%s", stamp, i, ext)
+	}
+	return name
+}
+
+// parseRotatedName returns the timestamp and counter of the name of a rotated file, without prefix and extension
+func parseRotatedName(name string) (time.Time, int, error) {
+	counter := 0
+	if i := strings.LastIndex(name, "-"); i >= 0 {
+		parsed, err := strconv.Atoi(name[i+1:])
+		if err != nil {
+			return time.Time{}, 0, err
+		}
+		name, counter = name[:i], parsed
+	}
+	timestamp, err := time.ParseInLocation(rotatedFileTimeFormat, name, time.UTC)
+	return timestamp, counter, err
+}
+
+func fileExists(path string) bool {
+	_, err := os.Lstat(path)
+	return err == nil
+}
+
+// prune removes rotated files beyond the retention count or older than the max age at the time of the rotation
+func (r *RotatingFileWriter) prune(now time.Time) {
+	if r.config.MaxBackups == 0 && r.config.MaxAge == 0 {
+		return
+	}
+	ext := filepath.Ext(r.path)
+	prefix := filepath.Base(strings.TrimSuffix(r.path, ext)) + "-"
+	entries, err := ioutil.ReadDir(filepath.Dir(r.path))
+	if err != nil {
+		return
+	}
+	type backup struct {
+		path      string
+		timestamp time.Time
+		counter   int
+	}
+	backups := []backup{}
+	for _, entry := range entries {
+		name := strings.TrimSuffix(entry.Name(), compressedFileSuffix)
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+			continue
+		}
+		timestamp, counter, err := parseRotatedName(strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext))
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backup{path: filepath.Join(filepath.Dir(r.path), entry.Name()), timestamp: timestamp, counter: counter})
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		if backups[i].timestamp.Equal(backups[j].timestamp) {
+			return backups[i].counter > backups[j].counter
+		}
+		return backups[i].timestamp.After(backups[j].timestamp)
+	})
+	cutoff := now.Add(-time.Duration(r.config.MaxAge) * time.Second)
+	for i, b := range backups {
+		if (r.config.MaxBackups > 0 && i >= r.config.MaxBackups) || (r.config.MaxAge > 0 && b.timestamp.Before(cutoff)) {
+			os.Remove(b.path)
+		}
+	}
+}
+
+// compressFile gzips a file, removing the original once the compressed file is written
+func compressFile(path string) error {
+	source, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer source.Close()
+	target, err := os.OpenFile(path+compressedFileSuffix, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0666)
+	if err != nil {
+		return err
+	}
+	writer := gzip.NewWriter(target)
+	if _, err := io.Copy(writer, source); err != nil {
+		target.Close()
+		os.Remove(path + compressedFileSuffix)
+		return err
+	}
+	if err := writer.Close(); err != nil {
+		target.Close()
+		os.Remove(path + compressedFileSuffix)
+		return err
+	}
+	if err := target.Close(); err != nil {
+		return err
+	}
+	return os.Remove(path)
+}
+
+// reopenOnSIGHUP registers a writer to be reopened whenever the process receives SIGHUP
+func reopenOnSIGHUP(writer *RotatingFileWriter) {
+	sighupLock.Lock()
+	sighupWriters = append(sighupWriters, writer)
+	sighupLock.Unlock()
+	sighupOnce.Do(func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, syscall.SIGHUP)
+		go func() {
+			for range signals {
+				sighupLock.Lock()
+				writers := append([]*RotatingFileWriter{}, sighupWriters...)
+				sighupLock.Unlock()
+				for _, w := range writers {
+					w.Reopen()
+				}
+			}
+		}()
+	})
+}
+
+// unregisterSIGHUP stops reopening a writer on SIGHUP
+func unregisterSIGHUP(writer *RotatingFileWriter) {
+	sighupLock.Lock()
+	defer sighupLock.Unlock()
+	for i, w := range sighupWriters {
+		if w == writer {
+			sighupWriters = append(sighupWriters[:i], sighupWriters[i+1:]...)
+			return
+		}
+	}
+}
@@ -0,0 +1,210 @@
+// Copyright 2020 Daniel Akiva
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goserv
+
+import (
+	"compress/gzip"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func listDir(t *testing.T, dir string) []string {
+	infos, err := ioutil.ReadDir(dir)
+	assert.NoError(t, err)
+	names := []string{}
+	for _, info := range infos {
+		names = append(names, info.Name())
+	}
+	sort.Strings(names)
+	return names
+}
+
+func TestRotateBySize(t *testing.T) {
+	// given
+	dir, _ := ioutil.TempDir("", "rotation")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "app.log")
+	writer, err := NewRotatingFileWriter(path, &FileRotationConfig{MaxSize: 10, MaxBackups: 2})
+	assert.NoError(t, err)
+	clock := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	writer.now = func() time.Time { return clock }
+
+	// when
+	for i := 0; i < 4; i++ {
+		clock = clock.Add(time.Second)
+		_, err := writer.Write([]byte("0123456789"))
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, writer.Close())
+
+	// then
+	assert.Equal(t, []string{"app-20200102T030408.000.log", "app-20200102T030409.000.log", "app.log"}, listDir(t, dir))
+	data, _ := ioutil.ReadFile(path)
+	assert.Equal(t, "0123456789", string(data))
+}
+
+func TestRotateByTimeWithCompression(t *testing.T) {
+	// given
+	dir, _ := ioutil.TempDir("", "rotation")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "app.log")
+	clock := time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC)
+	writer, err := NewRotatingFileWriter(path, &FileRotationConfig{RotationInterval: 60, Compress: true})
+	assert.NoError(t, err)
+	writer.now = func() time.Time { return clock }
+	writer.openedAt = clock
+
+	// when
+	writer.Write([]byte("first\n"))
+	clock = clock.Add(time.Minute)
+	writer.Write([]byte("second\n"))
+	assert.NoError(t, writer.Close())
+
+	// then
+	assert.Equal(t, []string{"app-20200102T030505.000.log.gz", "app.log"}, listDir(t, dir))
+	compressed, _ := os.Open(filepath.Join(dir, "app-20200102T030505.000.log.gz"))
+	defer compressed.Close()
+	reader, err := gzip.NewReader(compressed)
+	assert.NoError(t, err)
+	data, _ := ioutil.ReadAll(reader)
+	assert.Equal(t, "first\n", string(data))
+}
+
+func TestPruneByAge(t *testing.T) {
+	// given
+	dir, _ := ioutil.TempDir("", "rotation")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "app.log")
+	ioutil.WriteFile(filepath.Join(dir, "app-20191201T000000.000.log"), []byte("old"), 0666)
+	ioutil.WriteFile(filepath.Join(dir, "other.log"), []byte("other"), 0666)
+	writer, err := NewRotatingFileWriter(path, &FileRotationConfig{MaxAge: 86400})
+	assert.NoError(t, err)
+	writer.now = func() time.Time { return time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC) }
+
+	// when
+	assert.NoError(t, writer.Rotate())
+	assert.NoError(t, writer.Close())
+
+	// then
+	assert.Equal(t, []string{"app-20200102T030405.000.log", "app.log", "other.log"}, listDir(t, dir))
+}
+
+func TestRotateWithinSameMillisecond(t *testing.T) {
+	// given
+	dir, _ := ioutil.TempDir("", "rotation")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "app.log")
+	writer, err := NewRotatingFileWriter(path, &FileRotationConfig{MaxBackups: 2})
+	assert.NoError(t, err)
+	writer.now = func() time.Time { return time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC) }
+
+	// when
+	for _, line := range []string{"first\n", "second\n", "third\n"} {
+		writer.Write([]byte(line))
+		assert.NoError(t, writer.Rotate())
+	}
+	assert.NoError(t, writer.Close())
+
+	// then
+	assert.Equal(t, []string{"app-20200102T030405.000-1.log", "app-20200102T030405.000-2.log", "app.log"}, listDir(t, dir))
+	data, _ := ioutil.ReadFile(filepath.Join(dir, "app-20200102T030405.000-2.log"))
+	assert.Equal(t, "third\n", string(data))
+}
+
+func TestRotateFailureKeepsFile(t *testing.T) {
+	// given
+	dir, _ := ioutil.TempDir("", "rotation")
+	defer os.RemoveAll(dir)
+	logDir := filepath.Join(dir, "logs")
+	os.Mkdir(logDir, 0755)
+	writer, err := NewRotatingFileWriter(filepath.Join(logDir, "app.log"), &FileRotationConfig{MaxSize: 10})
+	assert.NoError(t, err)
+	defer writer.Close()
+	writer.Write([]byte("before\n"))
+
+	// when
+	os.RemoveAll(logDir)
+	rotateErr := writer.Rotate()
+	n, writeErr := writer.Write([]byte("after rotation failed\n"))
+	_, nextErr := writer.Write([]byte("after\n"))
+
+	// then
+	assert.Error(t, rotateErr)
+	assert.Error(t, writeErr)
+	assert.Equal(t, 22, n)
+	assert.NotEqual(t, os.ErrClosed, nextErr)
+}
+
+func TestReopen(t *testing.T) {
+	// given
+	dir, _ := ioutil.TempDir("", "rotation")
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "app.log")
+	writer, err := NewRotatingFileWriter(path, &FileRotationConfig{})
+	assert.NoError(t, err)
+	writer.Write([]byte("before\n"))
+
+	// when
+	os.Rename(path, path+".1")
+	assert.NoError(t, writer.Reopen())
+	writer.Write([]byte("after\n"))
+	assert.NoError(t, writer.Close())
+
+	// then
+	data, _ := ioutil.ReadFile(path)
+	assert.Equal(t, "after\n", string(data))
+}
+
+func TestReopenFailureKeepsFile(t *testing.T) {
+	// given
+	dir, _ := ioutil.TempDir("", "rotation")
+	defer os.RemoveAll(dir)
+	logDir := filepath.Join(dir, "logs")
+	os.Mkdir(logDir, 0755)
+	writer, err := NewRotatingFileWriter(filepath.Join(logDir, "app.log"), &FileRotationConfig{})
+	assert.NoError(t, err)
+	defer writer.Close()
+
+	// when
+	os.RemoveAll(logDir)
+	reopenErr := writer.Reopen()
+	_, writeErr := writer.Write([]byte("after\n"))
+
+	// then
+	assert.Error(t, reopenErr)
+	assert.NoError(t, writeErr)
+}
+
+func TestCloseUnregistersSIGHUP(t *testing.T) {
+	// given
+	dir, _ := ioutil.TempDir("", "rotation")
+	defer os.RemoveAll(dir)
+	writer, err := NewRotatingFileWriter(filepath.Join(dir, "app.log"), &FileRotationConfig{ReopenOnSIGHUP: true})
+	assert.NoError(t, err)
+
+	// when
+	assert.NoError(t, writer.Close())
+
+	// then
+	sighupLock.Lock()
+	defer sighupLock.Unlock()
+	assert.NotContains(t, sighupWriters, writer)
+}
@@ -39,6 +39,11 @@ type ServiceConfig struct {
 	Logging             *LoggingConfig             `json:"logging"`
 	OAuth2Service       *OAuth2ServiceConfig       `json:"oauth2_service"`
 	OpenIDConnectClient *OpenIDConnectClientConfig `json:"openid_connect_client"`
+	CORS                *CORSConfig                `json:"cors"`
+	RateLimit           *RateLimitConfig           `json:"rate_limit"`
+	Compression         *CompressionConfig         `json:"compression"`
+	Errors              *ErrorConfig               `json:"errors"`
+	RequestBody         *RequestBodyConfig         `json:"request_body"`
 }
 
 // NewServiceConfig intializes a new instance
@@ -83,5 +88,30 @@ func (s *ServiceConfig) Validate() error {
 			return err
 		}
 	}
+	if s.CORS != nil {
+		if err := s.CORS.Validate(); err != nil {
+			return err
+		}
+	}
+	if s.RateLimit != nil {
+		if err := s.RateLimit.Validate(); err != nil {
+			return err
+		}
+	}
+	if s.Compression != nil {
+		if err := s.Compression.Validate(); err != nil {
+			return err
+		}
+	}
+	if s.Errors != nil {
+		if err := s.Errors.Validate(); err != nil {
+			return err
+		}
+	}
+	if s.RequestBody != nil {
+		if err := s.RequestBody.Validate(); err != nil {
+			return err
+		}
+	}
 	return nil
 }
@@ -52,12 +52,68 @@ func TestLoadServiceConfig(t *testing.T) {
 		SwaggerFilePath: ".",
 	}
 	expectedConfig.Logging = &LoggingConfig{
-		LogLevel: "DEBUG",
-		Format:   "%{time} %{shortfile} %{level} %{message}",
+		LogLevel:     "DEBUG",
+		ModuleLevels: map[string]string{"db": "INFO", "http": "WARNING"},
+		Format:       "%{time} %{shortfile} %{level} %{message}",
+		BodyLogging: &BodyLoggingConfig{
+			LogRequestBody:    true,
+			LogResponseBody:   true,
+			MaxBodySize:       2048,
+			ContentTypes:      []string{"application/json"},
+			RedactHeaders:     []string{"X-Session"},
+			RedactQueryParams: []string{"token"},
+			RedactFields:      []string{"password", "customer.ssn"},
+		},
+		AccessLog: &AccessLogConfig{
+			Format:         "ECS",
+			TrustedProxies: []string{"10.0.0.0/8"},
+			Backends: []BackendConfig{
+				BackendConfig{
+					BackendName: "FILE",
+					FilePath:    "/home/centos/access.log",
+				},
+			},
+		},
+		Sampling: &SamplingConfig{
+			ReportInterval: 30,
+			Rules: []SamplingRule{
+				SamplingRule{
+					Module:     "db",
+					Interval:   1,
+					First:      10,
+					Thereafter: 100,
+				},
+			},
+		},
 		Backends: []BackendConfig{
 			BackendConfig{
 				BackendName: "FILE",
 				FilePath:    "/home/centos/temp.log",
+				LogLevel:    "INFO",
+				Formatter:   "JSON",
+				Async: &AsyncConfig{
+					QueueSize:      4096,
+					OverflowPolicy: "DROP_BELOW_LEVEL",
+					DropLevel:      "WARNING",
+				},
+				Rotation: &FileRotationConfig{
+					MaxSize:          10485760,
+					RotationInterval: 86400,
+					MaxBackups:       7,
+					MaxAge:           604800,
+					Compress:         true,
+					ReopenOnSIGHUP:   true,
+				},
+			},
+			BackendConfig{
+				BackendName: "SYSLOG",
+				LogLevel:    "ERROR",
+				Syslog: &SyslogConfig{
+					Network:  "tls",
+					Address:  "logs.example.com:6514",
+					Facility: "LOCAL0",
+					Tag:      "goserv",
+				},
 			},
 		},
 	}
@@ -73,6 +129,49 @@ func TestLoadServiceConfig(t *testing.T) {
 		Issuer:       "http://account.example.com",
 		RedirectURL:  "http://localhost/authorize/callback",
 	}
+	expectedConfig.CORS = &CORSConfig{
+		AllowedOrigins:   []string{"https://app.example.com", "https://*.example.org"},
+		AllowedMethods:   []string{"GET", "POST"},
+		AllowedHeaders:   []string{"Authorization", "Content-Type"},
+		ExposedHeaders:   []string{"Location"},
+		AllowCredentials: true,
+		MaxAge:           600,
+	}
+	expectedConfig.RateLimit = &RateLimitConfig{
+		KeyBy:          "SUBJECT",
+		TrustedProxies: []string{"10.0.0.0/8"},
+		Rules: []RateLimitRule{
+			RateLimitRule{
+				Pattern:  "/users/:id",
+				Methods:  []string{"PUT"},
+				Requests: 10,
+				Period:   60,
+			},
+			RateLimitRule{
+				Requests: 100,
+				Period:   1,
+				Burst:    200,
+			},
+		},
+	}
+	expectedConfig.Compression = &CompressionConfig{
+		MinSize:          512,
+		ContentTypes:     []string{"application/json", "text/*"},
+		ExcludedPrefixes: []string{"/apidocs/"},
+	}
+	expectedConfig.Errors = &ErrorConfig{
+		Format:             "PROBLEM",
+		ProblemTypeBaseURI: "https://example.com/problems/",
+		Mode:               "DEVELOPMENT",
+		IncludeStackTrace:  true,
+	}
+	expectedConfig.RequestBody = &RequestBodyConfig{
+		DisallowUnknownFields: true,
+		DisallowDuplicateKeys: true,
+		UseNumber:             true,
+		MaxBodySize:           1048576,
+		MaxDepth:              32,
+	}
 	// when
 	config := &ServiceConfig{}
 	err := LoadServiceConfig("service_config_test.json", config)
@@ -0,0 +1,270 @@
+// Copyright 2020 Daniel Akiva
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goserv
+
+import (
+	"fmt"
+	"net/mail"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"unicode/utf8"
+)
+
+const (
+	validateTag = "validate"
+)
+
+// validationRule represents a single rule parsed from a validate struct tag, ie max=255
+type validationRule struct {
+	name  string
+	param string
+}
+
+// validatedField represents an exported struct field along with its JSON name and parsed rules
+type validatedField struct {
+	index     int
+	name      string
+	anonymous bool
+	rules     []validationRule
+}
+
+var validatedFieldCache sync.Map
+
+// ValidateStruct validates a struct, or pointer to a struct, against the rules declared in its validate struct tags. Nested structs, slices, arrays and maps are validated recursively.
+// Supported rules are required, omitempty, min=N, max=N, len=N, email and oneof=a b c. min, max and len apply to the length of strings, slices and maps and to the value of numbers.
+// Returns a ValidationError listing every invalid field, identified by the JSON pointer built from the json field names.
+func ValidateStruct(value interface{}) error {
+	builder := NewValidationBuilder()
+	if err := validateValue(builder, "", reflect.ValueOf(value), nil); err != nil {
+		return err
+	}
+	return builder.Error()
+}
+
+func validateValue(builder *ValidationBuilder, path string, v reflect.Value, rules []validationRule) error {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			if hasRule(rules, "required") {
+				builder.Add(pathOrRoot(path), "required", "value is required")
+			}
+			return nil
+		}
+		v = v.Elem()
+	}
+	if !v.IsValid() {
+		return nil
+	}
+	if err := applyRules(builder, path, v, rules); err != nil {
+		return err
+	}
+	switch v.Kind() {
+	case reflect.Struct:
+		return validateStructFields(builder, path, v)
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			if err := validateValue(builder, path+JSONPointer(i), v.Index(i), nil); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		iter := v.MapRange()
+		for iter.Next() {
+			if err := validateValue(builder, path+JSONPointer(iter.Key().Interface()), iter.Value(), nil); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func validateStructFields(builder *ValidationBuilder, path string, v reflect.Value) error {
+	fields, err := validatedFields(v.Type())
+	if err != nil {
+		return err
+	}
+	for _, field := range fields {
+		fieldPath := path
+		if !field.anonymous {
+			fieldPath += JSONPointer(field.name)
+		}
+		if err := validateValue(builder, fieldPath, v.Field(field.index), field.rules); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyRules(builder *ValidationBuilder, path string, v reflect.Value, rules []validationRule) error {
+	if len(rules) == 0 {
+		return nil
+	}
+	path = pathOrRoot(path)
+	empty := isEmptyValue(v)
+	if empty && hasRule(rules, "required") {
+		builder.Add(path, "required", "value is required")
+		return nil
+	}
+	if empty && hasRule(rules, "omitempty") {
+		return nil
+	}
+	for _, rule := range rules {
+		switch rule.name {
+		case "required", "omitempty":
+		case "min", "max", "len":
+			limit, err := strconv.ParseFloat(rule.param, 64)
+			if err != nil {
+				return fmt.Errorf("invalid %s validation rule parameter %s: %w", rule.name, rule.param, err)
+			}
+			size, unit, ok := sizeOf(v)
+			if !ok {
+				return fmt.Errorf("%s validation rule does not apply to %v", rule.name, v.Type())
+			}
+			switch {
+			case rule.name == "min" && size < limit:
+				builder.Add(path, rule.name, fmt.Sprintf("must be at least %v%v", rule.param, unit))
+			case rule.name == "max" && size > limit:
+				builder.Add(path, rule.name, fmt.Sprintf("must be at most %v%v", rule.param, unit))
+			case rule.name == "len" && size != limit:
+				builder.Add(path, rule.name, fmt.Sprintf("must be exactly %v%v", rule.param, unit))
+			}
+		case "email":
+			if v.Kind() != reflect.String {
+				return fmt.Errorf("email validation rule does not apply to %v", v.Type())
+			}
+			if address, err := mail.ParseAddress(v.String()); err != nil || address.Address != v.String() {
+				builder.Add(path, rule.name, "must be a valid email address")
+			}
+		case "oneof":
+			options := strings.Fields(rule.param)
+			actual := fmt.Sprint(v.Interface())
+			if !methodMatch(options, actual) {
+				builder.Add(path, rule.name, fmt.Sprintf("must be one of [%v]", strings.Join(options, ", ")))
+			}
+		default:
+			return fmt.Errorf("unknown validation rule %s", rule.name)
+		}
+	}
+	return nil
+}
+
+// sizeOf returns the length of strings, slices, arrays and maps or the value of numbers, along with the unit used in messages
+func sizeOf(v reflect.Value) (float64, string, bool) {
+	switch v.Kind() {
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), " characters", true
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(v.Len()), " items", true
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), "", true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), "", true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), "", true
+	}
+	return 0, "", false
+}
+
+// isEmptyValue returns true for zero values, and for empty strings, slices and maps
+func isEmptyValue(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.String, reflect.Slice, reflect.Map, reflect.Array:
+		return v.Len() == 0
+	}
+	return v.IsZero()
+}
+
+func hasRule(rules []validationRule, name string) bool {
+	for _, rule := range rules {
+		if rule.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func pathOrRoot(path string) string {
+	if path == "" {
+		return "/"
+	}
+	return path
+}
+
+// validatedFields returns the exported fields of a struct type, caching the parsed rules per type
+func validatedFields(t reflect.Type) ([]validatedField, error) {
+	if cached, ok := validatedFieldCache.Load(t); ok {
+		return cached.([]validatedField), nil
+	}
+	fields := []validatedField{}
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		if f.PkgPath != "" {
+			continue
+		}
+		name, skip := jsonFieldName(f)
+		if skip {
+			continue
+		}
+		rules, err := parseValidationRules(f.Tag.Get(validateTag))
+		if err != nil {
+			return nil, fmt.Errorf("field %s of %v: %w", f.Name, t, err)
+		}
+		fields = append(fields, validatedField{
+			index:     i,
+			name:      name,
+			anonymous: f.Anonymous && f.Tag.Get("json") == "",
+			rules:     rules,
+		})
+	}
+	validatedFieldCache.Store(t, fields)
+	return fields, nil
+}
+
+// jsonFieldName returns the name of the field as encoded by encoding/json, and whether the field is skipped entirely
+func jsonFieldName(f reflect.StructField) (string, bool) {
+	tag := f.Tag.Get("json")
+	if tag == "-" {
+		return "", true
+	}
+	if name := strings.Split(tag, ",")[0]; name != "" {
+		return name, false
+	}
+	return f.Name, false
+}
+
+func parseValidationRules(tag string) ([]validationRule, error) {
+	if tag == "" {
+		return nil, nil
+	}
+	rules := []validationRule{}
+	for _, part := range strings.Split(tag, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		rule := validationRule{name: part}
+		if idx := strings.Index(part, "="); idx >= 0 {
+			rule.name, rule.param = part[:idx], part[idx+1:]
+		}
+		switch rule.name {
+		case "required", "omitempty", "email", "min", "max", "len", "oneof":
+		default:
+			return nil, fmt.Errorf("unknown validation rule %s", rule.name)
+		}
+		rules = append(rules, rule)
+	}
+	return rules, nil
+}
@@ -0,0 +1,88 @@
+// Copyright 2020 Daniel Akiva
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goserv
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testOrderItem struct {
+	Name     string `json:"name" validate:"required,max=10"`
+	Quantity int    `json:"quantity" validate:"min=1"`
+}
+
+type testOrder struct {
+	Email    string          `json:"email" validate:"required,email"`
+	Status   string          `json:"status" validate:"oneof=open closed"`
+	Note     string          `json:"note,omitempty" validate:"omitempty,len=4"`
+	Items    []testOrderItem `json:"items" validate:"required"`
+	Shipping *testAddress    `json:"shipping" validate:"required"`
+}
+
+func TestValidateStruct(t *testing.T) {
+	// given
+	order := &testOrder{
+		Email:    "bob@example.com",
+		Status:   "open",
+		Items:    []testOrderItem{{Name: "book", Quantity: 1}},
+		Shipping: &testAddress{City: "Boston"},
+	}
+
+	// when
+	err := ValidateStruct(order)
+
+	// then
+	assert.NoError(t, err)
+}
+
+func TestValidateStructFieldErrors(t *testing.T) {
+	// given
+	order := &testOrder{
+		Email:  "bob",
+		Status: "pending",
+		Note:   "abc",
+		Items:  []testOrderItem{{Name: "book", Quantity: 1}, {Name: "encyclopedia", Quantity: 0}},
+	}
+
+	// when
+	err := ValidateStruct(order)
+
+	// then
+	assert.IsType(t, &ValidationError{}, err)
+	assert.Equal(t, []FieldError{
+		{Path: "/email", Rule: "email", Message: "must be a valid email address"},
+		{Path: "/status", Rule: "oneof", Message: "must be one of [open, closed]"},
+		{Path: "/note", Rule: "len", Message: "must be exactly 4 characters"},
+		{Path: "/items/1/name", Rule: "max", Message: "must be at most 10 characters"},
+		{Path: "/items/1/quantity", Rule: "min", Message: "must be at least 1"},
+		{Path: "/shipping", Rule: "required", Message: "value is required"},
+	}, err.(*ValidationError).FieldErrors)
+}
+
+func TestValidateStructUnknownRule(t *testing.T) {
+	// given
+	value := &struct {
+		Name string `json:"name" validate:"uuid"`
+	}{Name: "bob"}
+
+	// when
+	err := ValidateStruct(value)
+
+	// then
+	assert.Error(t, err)
+	assert.NotEqual(t, &ValidationError{}, err)
+}
@@ -19,6 +19,9 @@ import (
 	"fmt"
 	"net/http"
 	"os"
+	"reflect"
+	"strconv"
+	"strings"
 
 	"github.com/emicklei/go-restful"
 	openapi "github.com/emicklei/go-restful-openapi"
@@ -58,7 +61,144 @@ func (s *SwaggerConfig) InstallSwaggerService(info *spec.Info, securityDefinitio
 			swo.Info = info
 			swo.SecurityDefinitions = securityDefinitions
 			swo.Security = security
+			addErrorCatalog(swo)
+			addValidationConstraints(swo, container.RegisteredWebServices())
 		}}
 	container.Add(openapi.NewOpenAPIService(config))
 	container.ServeMux.Handle(s.SwaggerPath, http.StripPrefix(s.SwaggerPath, http.FileServer(http.Dir(s.SwaggerFilePath))))
 }
+
+// addErrorCatalog documents the registered error codes as the ErrorCode definition, an x-error-codes extension listing each code's status and default message, and as the allowed values of the ErrorBody code.
+func addErrorCatalog(swo *spec.Swagger) {
+	defs := ErrorCodes()
+	codes := make([]interface{}, len(defs))
+	for i, def := range defs {
+		codes[i] = def.Code
+	}
+	if swo.Definitions == nil {
+		swo.Definitions = spec.Definitions{}
+	}
+	swo.Definitions["ErrorCode"] = *spec.StringProperty().WithEnum(codes...).WithDescription("The machine readable application error code.")
+	for name, schema := range swo.Definitions {
+		if name == "ErrorBody" || strings.HasSuffix(name, ".ErrorBody") {
+			if code, ok := schema.Properties["code"]; ok {
+				schema.Properties["code"] = *code.WithEnum(codes...)
+			}
+		}
+	}
+	if swo.Extensions == nil {
+		swo.Extensions = spec.Extensions{}
+	}
+	swo.Extensions.Add("x-error-codes", defs)
+}
+
+// addValidationConstraints documents the validate struct tags of the request, response and error models of the web services as schema constraints, ie max=255 as maxLength on a string.
+func addValidationConstraints(swo *spec.Swagger, webServices []*restful.WebService) {
+	visited := map[reflect.Type]bool{}
+	for _, ws := range webServices {
+		for _, route := range ws.Routes() {
+			addTypeConstraints(swo, reflect.TypeOf(route.ReadSample), visited)
+			addTypeConstraints(swo, reflect.TypeOf(route.WriteSample), visited)
+			for _, responseErr := range route.ResponseErrors {
+				addTypeConstraints(swo, reflect.TypeOf(responseErr.Model), visited)
+			}
+		}
+	}
+}
+
+func addTypeConstraints(swo *spec.Swagger, t reflect.Type, visited map[reflect.Type]bool) {
+	if t == nil {
+		return
+	}
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct || visited[t] {
+		return
+	}
+	visited[t] = true
+	fields, err := validatedFields(t)
+	if err != nil {
+		return
+	}
+	schema, hasSchema := swo.Definitions[t.String()]
+	for _, field := range fields {
+		fieldType := t.Field(field.index).Type
+		addTypeConstraints(swo, fieldType, visited)
+		if !hasSchema || field.anonymous {
+			continue
+		}
+		property, ok := schema.Properties[field.name]
+		if !ok {
+			continue
+		}
+		for fieldType.Kind() == reflect.Ptr {
+			fieldType = fieldType.Elem()
+		}
+		for _, rule := range field.rules {
+			applySchemaConstraint(&schema, &property, field.name, fieldType, rule)
+		}
+		schema.Properties[field.name] = property
+	}
+	if hasSchema {
+		swo.Definitions[t.String()] = schema
+	}
+}
+
+func applySchemaConstraint(schema *spec.Schema, property *spec.Schema, name string, t reflect.Type, rule validationRule) {
+	switch rule.name {
+	case "required":
+		for _, required := range schema.Required {
+			if required == name {
+				return
+			}
+		}
+		schema.Required = append(schema.Required, name)
+	case "email":
+		property.Format = "email"
+	case "oneof":
+		options := strings.Fields(rule.param)
+		enum := make([]interface{}, len(options))
+		for i, option := range options {
+			enum[i] = option
+		}
+		property.Enum = enum
+	case "min", "max", "len":
+		limit, err := strconv.ParseFloat(rule.param, 64)
+		if err != nil {
+			return
+		}
+		if rule.name == "min" || rule.name == "len" {
+			setLowerBound(property, t, limit)
+		}
+		if rule.name == "max" || rule.name == "len" {
+			setUpperBound(property, t, limit)
+		}
+	}
+}
+
+func setLowerBound(property *spec.Schema, t reflect.Type, limit float64) {
+	switch t.Kind() {
+	case reflect.String:
+		property.WithMinLength(int64(limit))
+	case reflect.Slice, reflect.Array:
+		property.WithMinItems(int64(limit))
+	case reflect.Map:
+		property.WithMinProperties(int64(limit))
+	default:
+		property.WithMinimum(limit, false)
+	}
+}
+
+func setUpperBound(property *spec.Schema, t reflect.Type, limit float64) {
+	switch t.Kind() {
+	case reflect.String:
+		property.WithMaxLength(int64(limit))
+	case reflect.Slice, reflect.Array:
+		property.WithMaxItems(int64(limit))
+	case reflect.Map:
+		property.WithMaxProperties(int64(limit))
+	default:
+		property.WithMaximum(limit, false)
+	}
+}
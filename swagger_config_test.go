@@ -15,8 +15,11 @@
 package goserv
 
 import (
+	"net/http"
 	"testing"
 
+	"github.com/emicklei/go-restful"
+	"github.com/go-openapi/spec"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -76,3 +79,55 @@ func TestInvalidSwaggerFilePathSwaggerConfig(t *testing.T) {
 	// then
 	assert.Error(t, err)
 }
+
+func TestAddErrorCatalog(t *testing.T) {
+	// given
+	RegisterErrorCode("ACCOUNT_LOCKED", http.StatusLocked, "account locked")
+	swo := &spec.Swagger{}
+	swo.Definitions = spec.Definitions{
+		"goserv.ErrorBody": *new(spec.Schema).WithProperties(map[string]spec.Schema{
+			"code": *spec.StringProperty(),
+		}),
+	}
+
+	// when
+	addErrorCatalog(swo)
+
+	// then
+	assert.Contains(t, swo.Definitions["ErrorCode"].Enum, "ACCOUNT_LOCKED")
+	assert.Contains(t, swo.Definitions["goserv.ErrorBody"].Properties["code"].Enum, ErrorCodeResourceNotFound)
+	defs, ok := swo.Extensions["x-error-codes"].([]ErrorCodeDefinition)
+	assert.True(t, ok)
+	assert.Contains(t, defs, ErrorCodeDefinition{Code: "ACCOUNT_LOCKED", StatusCode: http.StatusLocked, Message: "account locked"})
+}
+
+func TestAddValidationConstraints(t *testing.T) {
+	// given
+	ws := new(restful.WebService)
+	ws.Route(ws.POST("/orders").To(func(*restful.Request, *restful.Response) {}).Reads(testOrder{}))
+	swo := &spec.Swagger{}
+	swo.Definitions = spec.Definitions{
+		"goserv.testOrder": *new(spec.Schema).WithProperties(map[string]spec.Schema{
+			"email":    *spec.StringProperty(),
+			"status":   *spec.StringProperty(),
+			"items":    *spec.ArrayProperty(spec.RefProperty("#/definitions/goserv.testOrderItem")),
+			"shipping": *spec.RefProperty("#/definitions/goserv.testAddress"),
+		}),
+		"goserv.testOrderItem": *new(spec.Schema).WithProperties(map[string]spec.Schema{
+			"name":     *spec.StringProperty(),
+			"quantity": *spec.Int64Property(),
+		}),
+	}
+
+	// when
+	addValidationConstraints(swo, []*restful.WebService{ws})
+
+	// then
+	order := swo.Definitions["goserv.testOrder"]
+	assert.ElementsMatch(t, []string{"email", "items", "shipping"}, order.Required)
+	assert.Equal(t, "email", order.Properties["email"].Format)
+	assert.Equal(t, []interface{}{"open", "closed"}, order.Properties["status"].Enum)
+	item := swo.Definitions["goserv.testOrderItem"]
+	assert.Equal(t, int64(10), *item.Properties["name"].MaxLength)
+	assert.Equal(t, float64(1), *item.Properties["quantity"].Minimum)
+}
@@ -0,0 +1,402 @@
+// Copyright 2020 Daniel Akiva
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goserv
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log/syslog"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/op/go-logging"
+)
+
+const (
+	// SyslogNetworkUDP ships log records to a remote syslog server over UDP, one record per datagram
+	SyslogNetworkUDP = "udp"
+	// SyslogNetworkTCP ships log records to a remote syslog server over TCP using octet counting framing
+	SyslogNetworkTCP = "tcp"
+	// SyslogNetworkTLS ships log records to a remote syslog server over TLS using octet counting framing
+	SyslogNetworkTLS = "tls"
+
+	// DefaultSyslogDialTimeout is the default number of seconds to wait for a connection to a remote syslog server
+	DefaultSyslogDialTimeout = 5
+	// DefaultSyslogBufferSize is the default number of records buffered while a remote syslog server is unreachable
+	DefaultSyslogBufferSize = 1000
+	// DefaultSyslogReconnectInterval is the default minimum number of seconds between attempts to reconnect to a remote syslog server
+	DefaultSyslogReconnectInterval = 5
+	// DefaultSyslogWriteTimeout is the default number of seconds to wait for a remote syslog server to accept a record
+	DefaultSyslogWriteTimeout = 5
+
+	rfc5424TimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+)
+
+var syslogFacilities = map[string]syslog.Priority{
+	"KERN":     syslog.LOG_KERN,
+	"USER":     syslog.LOG_USER,
+	"MAIL":     syslog.LOG_MAIL,
+	"DAEMON":   syslog.LOG_DAEMON,
+	"AUTH":     syslog.LOG_AUTH,
+	"SYSLOG":   syslog.LOG_SYSLOG,
+	"LPR":      syslog.LOG_LPR,
+	"NEWS":     syslog.LOG_NEWS,
+	"UUCP":     syslog.LOG_UUCP,
+	"CRON":     syslog.LOG_CRON,
+	"AUTHPRIV": syslog.LOG_AUTHPRIV,
+	"FTP":      syslog.LOG_FTP,
+	"LOCAL0":   syslog.LOG_LOCAL0,
+	"LOCAL1":   syslog.LOG_LOCAL1,
+	"LOCAL2":   syslog.LOG_LOCAL2,
+	"LOCAL3":   syslog.LOG_LOCAL3,
+	"LOCAL4":   syslog.LOG_LOCAL4,
+	"LOCAL5":   syslog.LOG_LOCAL5,
+	"LOCAL6":   syslog.LOG_LOCAL6,
+	"LOCAL7":   syslog.LOG_LOCAL7,
+}
+
+// SyslogConfig represents settings of a SYSLOG logging backend. If no network is set records are written to the local syslog daemon, otherwise they are shipped to a remote server in RFC 5424 format.
+type SyslogConfig struct {
+	// Network is one of [udp, tcp, tls], empty to write to the local syslog daemon
+	Network string `json:"network"`
+	// Address is the host:port of the remote syslog server
+	Address string `json:"address"`
+	// Facility is the syslog facility, ie USER, DAEMON or LOCAL0 to LOCAL7, defaulting to USER
+	Facility string `json:"facility"`
+	// Tag identifies the service, defaulting to the name of the executable
+	Tag string `json:"tag"`
+	// CAFile is a PEM file holding the certificate authorities used to verify a TLS server, defaulting to the system pool
+	CAFile             string `json:"ca_file"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify"`
+	// DialTimeout is the number of seconds to wait for a connection
+	DialTimeout int `json:"dial_timeout"`
+	// BufferSize is the number of records buffered while the server is unreachable, the oldest records are dropped once the buffer is full
+	BufferSize int `json:"buffer_size"`
+	// ReconnectInterval is the minimum number of seconds between reconnect attempts
+	ReconnectInterval int `json:"reconnect_interval"`
+	// WriteTimeout is the number of seconds to wait for the server to accept a record before the connection is considered broken
+	WriteTimeout int `json:"write_timeout"`
+}
+
+// Validate ensures the configuration is valid
+func (s *SyslogConfig) Validate() error {
+	switch s.Network {
+	case "":
+	case SyslogNetworkUDP, SyslogNetworkTCP, SyslogNetworkTLS:
+		if s.Address == "" {
+			return errors.New("remote syslog requires an address to be set")
+		}
+		if _, _, err := net.SplitHostPort(s.Address); err != nil {
+			return fmt.Errorf("invalid syslog address %s: %w", s.Address, err)
+		}
+	default:
+		return fmt.Errorf("invalid syslog network %s, must be one of [udp, tcp, tls]", s.Network)
+	}
+	if _, err := s.facility(); err != nil {
+		return err
+	}
+	if s.CAFile != "" && s.Network != SyslogNetworkTLS {
+		return errors.New("a CA file is only supported by the tls network")
+	}
+	if s.DialTimeout < 0 || s.BufferSize < 0 || s.ReconnectInterval < 0 || s.WriteTimeout < 0 {
+		return errors.New("syslog dial timeout, buffer size, reconnect interval and write timeout must not be negative")
+	}
+	return nil
+}
+
+func (s *SyslogConfig) facility() (syslog.Priority, error) {
+	if s.Facility == "" {
+		return syslog.LOG_USER, nil
+	}
+	facility, ok := syslogFacilities[strings.ToUpper(s.Facility)]
+	if !ok {
+		return 0, fmt.Errorf("invalid syslog facility %s", s.Facility)
+	}
+	return facility, nil
+}
+
+func (s *SyslogConfig) tag() string {
+	if s.Tag != "" {
+		return s.Tag
+	}
+	return filepath.Base(os.Args[0])
+}
+
+// RemoteSyslogBackend is an op/go-logging backend shipping records to a remote syslog server in RFC 5424 format.
+// Records are buffered and sent from a background goroutine, so that a slow or unreachable server never blocks the logging caller. Buffered records are sent once the backend reconnects, which it attempts at most once per reconnect interval.
+type RemoteSyslogBackend struct {
+	config    SyslogConfig
+	facility  syslog.Priority
+	hostname  string
+	tag       string
+	tlsConfig *tls.Config
+	// lock guards the buffered records and the drop counter, the connection is owned by the sending goroutine
+	lock     sync.Mutex
+	pending  [][]byte
+	dropped  uint64
+	conn     net.Conn
+	lastDial time.Time
+	wake     chan struct{}
+	flushes  chan chan struct{}
+	stop     chan struct{}
+	done     chan struct{}
+	close    sync.Once
+}
+
+// NewRemoteSyslogBackend initializes a new backend, returning an error if the server cannot be reached
+func NewRemoteSyslogBackend(config *SyslogConfig) (*RemoteSyslogBackend, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	if config.Network == "" {
+		return nil, errors.New("remote syslog requires a network to be set")
+	}
+	facility, _ := config.facility()
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "-"
+	}
+	backend := &RemoteSyslogBackend{config: *config, facility: facility, hostname: hostname, tag: config.tag()}
+	if backend.config.DialTimeout == 0 {
+		backend.config.DialTimeout = DefaultSyslogDialTimeout
+	}
+	if backend.config.BufferSize == 0 {
+		backend.config.BufferSize = DefaultSyslogBufferSize
+	}
+	if backend.config.ReconnectInterval == 0 {
+		backend.config.ReconnectInterval = DefaultSyslogReconnectInterval
+	}
+	if backend.config.WriteTimeout == 0 {
+		backend.config.WriteTimeout = DefaultSyslogWriteTimeout
+	}
+	if config.Network == SyslogNetworkTLS {
+		host, _, _ := net.SplitHostPort(config.Address)
+		backend.tlsConfig = &tls.Config{ServerName: host, InsecureSkipVerify: config.InsecureSkipVerify}
+		if config.CAFile != "" {
+			pem, err := ioutil.ReadFile(config.CAFile)
+			if err != nil {
+				return nil, err
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificates found in %s", config.CAFile)
+			}
+			backend.tlsConfig.RootCAs = pool
+		}
+	}
+	if err := backend.dial(); err != nil {
+		return nil, err
+	}
+	backend.wake = make(chan struct{}, 1)
+	backend.flushes = make(chan chan struct{})
+	backend.stop = make(chan struct{})
+	backend.done = make(chan struct{})
+	go backend.run()
+	return backend, nil
+}
+
+// Log formats a record and buffers it for the sending goroutine, dropping the oldest buffered record if the buffer is full
+func (r *RemoteSyslogBackend) Log(level logging.Level, calldepth int, record *logging.Record) error {
+	message := r.format(level, record, record.Formatted(calldepth+1))
+	r.lock.Lock()
+	r.pending = append(r.pending, message)
+	if overflow := len(r.pending) - r.config.BufferSize; overflow > 0 {
+		r.pending = r.pending[overflow:]
+		r.dropped += uint64(overflow)
+	}
+	r.lock.Unlock()
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// Dropped returns the number of records dropped because the buffer was full
+func (r *RemoteSyslogBackend) Dropped() uint64 {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	return r.dropped
+}
+
+// Close stops the sending goroutine once it made a last attempt to send the buffered records, and closes the connection
+func (r *RemoteSyslogBackend) Close() error {
+	r.close.Do(func() { close(r.stop) })
+	<-r.done
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if len(r.pending) > 0 {
+		return fmt.Errorf("unable to send %d records to syslog server %s", len(r.pending), r.config.Address)
+	}
+	return nil
+}
+
+// run sends buffered records whenever records are logged, retrying once the reconnect interval elapses while the server is unreachable
+// Flush waits until the records buffered so far are sent, or the timeout elapses
+func (r *RemoteSyslogBackend) Flush(timeout time.Duration) error {
+	flushed := make(chan struct{})
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case r.flushes <- flushed:
+	case <-r.done:
+		return nil
+	case <-timer.C:
+		return fmt.Errorf("timed out flushing records to syslog server %s", r.config.Address)
+	}
+	select {
+	case <-flushed:
+	case <-r.done:
+	case <-timer.C:
+		return fmt.Errorf("timed out flushing records to syslog server %s", r.config.Address)
+	}
+	return nil
+}
+
+func (r *RemoteSyslogBackend) run() {
+	defer close(r.done)
+	retry := time.NewTimer(time.Hour)
+	retry.Stop()
+	// flushed are the Flush calls waiting for the buffer to be sent
+	flushed := []chan struct{}{}
+	for {
+		select {
+		case <-r.wake:
+		case <-retry.C:
+		case waiting := <-r.flushes:
+			flushed = append(flushed, waiting)
+		case <-r.stop:
+			r.flush()
+			if r.conn != nil {
+				r.conn.Close()
+				r.conn = nil
+			}
+			return
+		}
+		if wait := r.flush(); wait > 0 {
+			retry.Stop()
+			retry.Reset(wait)
+			continue
+		}
+		for _, waiting := range flushed {
+			close(waiting)
+		}
+		flushed = flushed[:0]
+	}
+}
+
+// format renders a record as an RFC 5424 message, using the module of the record as the message id
+func (r *RemoteSyslogBackend) format(level logging.Level, record *logging.Record, message string) []byte {
+	msgID := record.Module
+	if msgID == "" {
+		msgID = "-"
+	}
+	formatted := fmt.Sprintf("<%d>1 %s %s %s %d %s - %s",
+		r.facility|toSyslogPriority(level),
+		record.Time.Format(rfc5424TimeFormat),
+		syslogHeaderField(r.hostname, 255),
+		syslogHeaderField(r.tag, 48),
+		os.Getpid(),
+		syslogHeaderField(msgID, 32),
+		strings.TrimRight(message, "\n"))
+	if r.config.Network == SyslogNetworkUDP {
+		return []byte(formatted)
+	}
+	return []byte(fmt.Sprintf("%d %s", len(formatted), formatted))
+}
+
+// flush sends the buffered records, reconnecting if the reconnect interval has elapsed since the last attempt. Returns the duration to wait before retrying if records remain buffered.
+func (r *RemoteSyslogBackend) flush() time.Duration {
+	reconnectInterval := time.Duration(r.config.ReconnectInterval) * time.Second
+	for {
+		r.lock.Lock()
+		if len(r.pending) == 0 {
+			r.lock.Unlock()
+			return 0
+		}
+		r.lock.Unlock()
+		if r.conn == nil {
+			if since := time.Since(r.lastDial); since < reconnectInterval {
+				return reconnectInterval - since
+			}
+			if err := r.dial(); err != nil {
+				return reconnectInterval
+			}
+		}
+		r.lock.Lock()
+		message := r.pending[0]
+		r.pending = r.pending[1:]
+		r.lock.Unlock()
+		r.conn.SetWriteDeadline(time.Now().Add(time.Duration(r.config.WriteTimeout) * time.Second))
+		if _, err := r.conn.Write(message); err != nil {
+			// reconnect, at most once per reconnect interval, as a partially written record breaks the framing of the connection
+			r.conn.Close()
+			r.conn = nil
+			r.requeue(message)
+		}
+	}
+}
+
+// requeue returns a record that could not be sent to the front of the buffer, dropping it if the buffer filled up meanwhile
+func (r *RemoteSyslogBackend) requeue(message []byte) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if len(r.pending) >= r.config.BufferSize {
+		r.dropped++
+		return
+	}
+	r.pending = append([][]byte{message}, r.pending...)
+}
+
+func (r *RemoteSyslogBackend) dial() error {
+	r.lastDial = time.Now()
+	dialer := &net.Dialer{Timeout: time.Duration(r.config.DialTimeout) * time.Second}
+	var conn net.Conn
+	var err error
+	if r.config.Network == SyslogNetworkTLS {
+		conn, err = tls.DialWithDialer(dialer, "tcp", r.config.Address, r.tlsConfig)
+	} else {
+		conn, err = dialer.Dial(r.config.Network, r.config.Address)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to connect to syslog server %s: %w", r.config.Address, err)
+	}
+	r.conn = conn
+	return nil
+}
+
+// syslogHeaderField restricts a header field to printable ASCII without spaces and to a maximum length, as required by RFC 5424
+func syslogHeaderField(value string, maxLength int) string {
+	field := strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return '_'
+		}
+		return r
+	}, value)
+	if len(field) > maxLength {
+		field = field[:maxLength]
+	}
+	if field == "" {
+		return "-"
+	}
+	return field
+}
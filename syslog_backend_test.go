@@ -0,0 +1,282 @@
+// Copyright 2020 Daniel Akiva
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goserv
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/op/go-logging"
+	"github.com/stretchr/testify/assert"
+)
+
+func newSyslogTestLogger(backend logging.Backend) *logging.Logger {
+	leveled := logging.AddModuleLevel(logging.NewBackendFormatter(backend, logging.MustStringFormatter("%{message}")))
+	logger := logging.MustGetLogger("syslog_test")
+	logger.SetBackend(leveled)
+	return logger
+}
+
+// readOctetCounted reads a single octet counted syslog message
+func readOctetCounted(t *testing.T, reader *bufio.Reader) string {
+	length, err := reader.ReadString(' ')
+	assert.NoError(t, err)
+	size, err := strconv.Atoi(strings.TrimSpace(length))
+	assert.NoError(t, err)
+	message := make([]byte, size)
+	_, err = io.ReadFull(reader, message)
+	assert.NoError(t, err)
+	return string(message)
+}
+
+func TestRemoteSyslogUDP(t *testing.T) {
+	// given
+	listener, err := net.ListenPacket("udp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	backend, err := NewRemoteSyslogBackend(&SyslogConfig{Network: SyslogNetworkUDP, Address: listener.LocalAddr().String(), Facility: "LOCAL0", Tag: "orders"})
+	assert.NoError(t, err)
+	defer backend.Close()
+	logger := newSyslogTestLogger(backend)
+
+	// when
+	logger.Warning("disk almost full")
+
+	// then
+	buffer := make([]byte, 1024)
+	listener.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := listener.ReadFrom(buffer)
+	assert.NoError(t, err)
+	// LOCAL0 (16) * 8 + WARNING (4)
+	pattern := regexp.MustCompile(`^<132>1 \d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}\.\d{6}\S+ \S+ orders \d+ syslog_test - disk almost full$`)
+	assert.Regexp(t, pattern, string(buffer[:n]))
+}
+
+// bufferedSyslogRecords returns the records buffered by a backend
+func bufferedSyslogRecords(backend *RemoteSyslogBackend) []string {
+	backend.lock.Lock()
+	defer backend.lock.Unlock()
+	records := []string{}
+	for _, record := range backend.pending {
+		records = append(records, string(record))
+	}
+	return records
+}
+
+// breakSyslogConnection closes the server side of a connection and logs until the backend notices, returning once records remain buffered
+func breakSyslogConnection(t *testing.T, backend *RemoteSyslogBackend, logger *logging.Logger, conn net.Conn) {
+	conn.Close()
+	assert.Eventually(t, func() bool {
+		logger.Info("probe")
+		time.Sleep(50 * time.Millisecond)
+		return len(bufferedSyslogRecords(backend)) > 0
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestRemoteSyslogTCPReconnect(t *testing.T) {
+	// given
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	address := listener.Addr().String()
+	backend, err := NewRemoteSyslogBackend(&SyslogConfig{Network: SyslogNetworkTCP, Address: address, Tag: "orders", ReconnectInterval: 1})
+	assert.NoError(t, err)
+	defer backend.Close()
+	conn, err := listener.Accept()
+	assert.NoError(t, err)
+	logger := newSyslogTestLogger(backend)
+	logger.Info("first")
+	assert.Contains(t, readOctetCounted(t, bufio.NewReader(conn)), "first")
+
+	// when
+	listener.Close()
+	breakSyslogConnection(t, backend, logger, conn)
+	logger.Info("reconnected")
+	pending := len(bufferedSyslogRecords(backend))
+	listener, err = net.Listen("tcp", address)
+	assert.NoError(t, err)
+	defer listener.Close()
+
+	// then
+	conn, err = listener.Accept()
+	assert.NoError(t, err)
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	messages := []string{}
+	for i := 0; i < pending; i++ {
+		messages = append(messages, readOctetCounted(t, reader))
+	}
+	assert.True(t, strings.HasSuffix(messages[len(messages)-1], "reconnected"))
+	assert.Eventually(t, func() bool { return len(bufferedSyslogRecords(backend)) == 0 }, time.Second, 10*time.Millisecond)
+}
+
+func TestRemoteSyslogBufferOverflow(t *testing.T) {
+	// given
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	backend, err := NewRemoteSyslogBackend(&SyslogConfig{Network: SyslogNetworkTCP, Address: listener.Addr().String(), BufferSize: 2, ReconnectInterval: 60})
+	assert.NoError(t, err)
+	defer backend.Close()
+	conn, err := listener.Accept()
+	assert.NoError(t, err)
+	logger := newSyslogTestLogger(backend)
+	breakSyslogConnection(t, backend, logger, conn)
+	dropped := backend.Dropped()
+
+	// when
+	for i := 0; i < 5; i++ {
+		logger.Infof("message %d", i)
+	}
+
+	// then
+	assert.True(t, backend.Dropped()-dropped >= 3)
+	records := bufferedSyslogRecords(backend)
+	assert.Len(t, records, 2)
+	assert.True(t, strings.HasSuffix(records[1], "message 4"))
+}
+
+func TestRemoteSyslogServerNotReading(t *testing.T) {
+	// given
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	backend, err := NewRemoteSyslogBackend(&SyslogConfig{Network: SyslogNetworkTCP, Address: listener.Addr().String(), BufferSize: 1000, WriteTimeout: 1})
+	assert.NoError(t, err)
+	conn, err := listener.Accept()
+	assert.NoError(t, err)
+	defer conn.Close()
+	logger := newSyslogTestLogger(backend)
+	message := strings.Repeat("x", 64*1024)
+
+	for i := 0; i < 500; i++ {
+		logger.Info(message)
+	}
+
+	// when
+	start := time.Now()
+	logger.Info("not blocked")
+	logged := time.Since(start)
+	start = time.Now()
+	closeErr := backend.Close()
+	closed := time.Since(start)
+
+	// then
+	assert.True(t, logged < 200*time.Millisecond, "logging blocked for %s", logged)
+	assert.Error(t, closeErr)
+	assert.True(t, closed < 3*time.Second, "closing blocked for %s", closed)
+}
+
+func TestRemoteSyslogUnreachable(t *testing.T) {
+	// given
+	listener, _ := net.Listen("tcp", "127.0.0.1:0")
+	address := listener.Addr().String()
+	listener.Close()
+	config := &LoggingConfig{
+		LogLevel: "INFO",
+		Backends: []BackendConfig{
+			BackendConfig{
+				BackendName: "SYSLOG",
+				Syslog:      &SyslogConfig{Network: SyslogNetworkTCP, Address: address},
+			},
+		},
+	}
+
+	// when
+	err := config.InitializeLogging()
+
+	// then
+	assert.Error(t, err)
+	assert.Contains(t, err.Error(), fmt.Sprintf("unable to connect to syslog server %s", address))
+}
+
+func TestInvalidSyslogConfig(t *testing.T) {
+	for _, config := range []*SyslogConfig{
+		&SyslogConfig{Network: "http", Address: "localhost:514"},
+		&SyslogConfig{Network: SyslogNetworkUDP},
+		&SyslogConfig{Network: SyslogNetworkTCP, Address: "localhost"},
+		&SyslogConfig{Facility: "LOCAL9"},
+		&SyslogConfig{Network: SyslogNetworkTCP, Address: "localhost:514", CAFile: "ca.pem"},
+	} {
+		// when
+		err := config.Validate()
+
+		// then
+		assert.Error(t, err)
+	}
+}
+
+func TestInitializeLoggingFlushesAndClosesRemoteSyslog(t *testing.T) {
+	// given
+	dir, _ := ioutil.TempDir("", "logging")
+	defer os.RemoveAll(dir)
+	defer logging.SetBackend(logging.NewLogBackend(os.Stderr, "", 0))
+	defer setLogLevels(nil)
+	defer setAsyncBackends(loggingAsyncBackends, nil, nil, time.Second)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	defer listener.Close()
+	received := make(chan string, 1)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			close(received)
+			return
+		}
+		data, _ := ioutil.ReadAll(conn)
+		received <- string(data)
+	}()
+	config := &LoggingConfig{
+		LogLevel: "INFO",
+		Format:   "%{message}",
+		Backends: []BackendConfig{BackendConfig{BackendName: "SYSLOG", Syslog: &SyslogConfig{Network: SyslogNetworkTCP, Address: listener.Addr().String()}}},
+	}
+	assert.NoError(t, config.InitializeLogging())
+	backend := backendClosers[loggingAsyncBackends][0].(*RemoteSyslogBackend)
+	logger := logging.MustGetLogger("syslog_flush_test")
+
+	// when
+	for i := 0; i < 100; i++ {
+		logger.Infof("order %d shipped", i)
+	}
+	flushErr := FlushLogging(time.Second)
+	buffered := bufferedSyslogRecords(backend)
+	config.Backends = []BackendConfig{BackendConfig{BackendName: "FILE", FilePath: filepath.Join(dir, "app.log")}}
+	assert.NoError(t, config.InitializeLogging())
+
+	// then
+	assert.NoError(t, flushErr)
+	assert.Empty(t, buffered)
+	select {
+	case data := <-received:
+		assert.Equal(t, 100, strings.Count(data, "shipped"))
+	case <-time.After(time.Second):
+		assert.Fail(t, "the connection of the replaced backend was not closed")
+	}
+	select {
+	case <-backend.done:
+	default:
+		assert.Fail(t, "the sending goroutine of the replaced backend is still running")
+	}
+}
@@ -24,6 +24,9 @@ import (
 
 const (
 	authorizationHeader = "Authorization"
+
+	// TokenSubjectAttribute is an attribute holding the subject (sub) claim of the validated token, set by the TokenAuthFilter
+	TokenSubjectAttribute = "token_subject_attr"
 )
 
 // TokenAuthFilter represents a go-restful authentication filter that validates a JWT-Token passed via an http header parameter. There is an additional optionalwWhitelist that may be set, allowing specific urls, methods, or url patterns to opt-out of authentication.
@@ -39,9 +42,10 @@ func (t *TokenAuthFilter) Filter(request *restful.Request, response *restful.Res
 	} else {
 		if token, err := parseToken(request.HeaderParameter(authorizationHeader)); err != nil {
 			http.Error(response, "Not Authorized", http.StatusUnauthorized)
-		} else if tokenErr := t.TokenManager.ValidateToken(token); tokenErr != nil {
+		} else if subject, tokenErr := t.TokenManager.Subject(token); tokenErr != nil {
 			http.Error(response, "Not Authorized", http.StatusUnauthorized)
 		} else {
+			request.SetAttribute(TokenSubjectAttribute, subject)
 			chain.ProcessFilter(request, response)
 		}
 	}
@@ -39,6 +39,12 @@ func NewTokenManager(signingKey []byte, tokenExpiration int) *TokenManager {
 
 // ValidateToken validates the token, returning an error if validation fails.
 func (t *TokenManager) ValidateToken(token string) error {
+	_, err := t.Claims(token)
+	return err
+}
+
+// Claims validates the token and returns its claims, returning an error if validation fails.
+func (t *TokenManager) Claims(token string) (jwt.MapClaims, error) {
 	keyFunc := func(token *jwt.Token) (interface{}, error) {
 		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
 			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
@@ -47,17 +53,40 @@ func (t *TokenManager) ValidateToken(token string) error {
 	}
 	parsedToken, err := jwt.Parse(token, keyFunc)
 	if err != nil || !parsedToken.Valid {
-		return errors.New("invalid token")
+		return nil, errors.New("invalid token")
+	}
+	claims, ok := parsedToken.Claims.(jwt.MapClaims)
+	if !ok {
+		return nil, errors.New("invalid token")
+	}
+	return claims, nil
+}
+
+// Subject validates the token and returns its subject (sub) claim, which is empty if the token carries no subject.
+func (t *TokenManager) Subject(token string) (string, error) {
+	claims, err := t.Claims(token)
+	if err != nil {
+		return "", err
 	}
-	return nil
+	sub, _ := claims["sub"].(string)
+	return sub, nil
 }
 
 // CreateToken creates, signs and returns a new JSON Web Token using the signing key and expiration provided.
 func (t *TokenManager) CreateToken() (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+	return t.CreateTokenWithSubject("")
+}
+
+// CreateTokenWithSubject creates, signs and returns a new JSON Web Token identifying the subject, using the signing key and expiration provided. An empty subject is omitted.
+func (t *TokenManager) CreateTokenWithSubject(subject string) (string, error) {
+	claims := jwt.MapClaims{
 		"iat": time.Now().Unix(),
 		"exp": time.Now().Add(time.Second * time.Duration(t.tokenExpiration)).Unix(),
-	})
+	}
+	if subject != "" {
+		claims["sub"] = subject
+	}
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
 	tokenString, err := token.SignedString(t.signingKey)
 	if err != nil {
 		return "", err
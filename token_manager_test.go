@@ -65,3 +65,18 @@ func TestExpiredToken(t *testing.T) {
 	// then
 	assert.Error(t, err)
 }
+
+func TestCreateTokenWithSubject(t *testing.T) {
+	// given
+	key := []byte("f2ca1bb6c7e907d06dafe4687e579fce76b37e4e93b7605022da52e6ccc26fd2")
+	manager := NewTokenManager(key, 60)
+	token, err := manager.CreateTokenWithSubject("user123")
+	assert.NoError(t, err)
+
+	// when
+	subject, err := manager.Subject(token)
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, "user123", subject)
+}
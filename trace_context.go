@@ -0,0 +1,190 @@
+// Copyright 2020 Daniel Akiva
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goserv
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	// TraceparentHeader is the W3C Trace Context header identifying the trace and the calling span
+	TraceparentHeader = "traceparent"
+	// TracestateHeader is the W3C Trace Context header carrying vendor specific trace state
+	TracestateHeader = "tracestate"
+	// TraceIDResponseHeader is the response header echoing the trace id of a request
+	TraceIDResponseHeader = "X-Trace-Id"
+
+	traceparentVersion = "00"
+	traceFlagSampled   = 0x01
+	maxTracestateSize  = 512
+)
+
+type traceContextKey struct{}
+
+// TraceContext represents the W3C Trace Context of a request handled by the service. SpanID identifies the handling of the request, ParentSpanID the span of the caller, if any.
+type TraceContext struct {
+	TraceID      string
+	SpanID       string
+	ParentSpanID string
+	Flags        byte
+	State        string
+}
+
+// NewTraceContext returns a sampled context starting a new trace
+func NewTraceContext() *TraceContext {
+	return &TraceContext{TraceID: randomHex(16), SpanID: randomHex(8), Flags: traceFlagSampled}
+}
+
+// ParseTraceparent parses a traceparent header, returning an error if the header is malformed. Versions newer than 00 are parsed as version 00, ignoring additional fields.
+func ParseTraceparent(value string) (*TraceContext, error) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 {
+		return nil, fmt.Errorf("invalid traceparent %q", value)
+	}
+	version, traceID, parentID, flags := parts[0], parts[1], parts[2], parts[3]
+	if !isLowerHex(version, 2) || version == "ff" || (version == traceparentVersion && len(parts) != 4) {
+		return nil, fmt.Errorf("invalid traceparent version %q", version)
+	}
+	if !isLowerHex(traceID, 32) || traceID == strings.Repeat("0", 32) {
+		return nil, fmt.Errorf("invalid trace id %q", traceID)
+	}
+	if !isLowerHex(parentID, 16) || parentID == strings.Repeat("0", 16) {
+		return nil, fmt.Errorf("invalid parent id %q", parentID)
+	}
+	if !isLowerHex(flags, 2) {
+		return nil, fmt.Errorf("invalid trace flags %q", flags)
+	}
+	decoded, _ := hex.DecodeString(flags)
+	return &TraceContext{TraceID: traceID, ParentSpanID: parentID, Flags: decoded[0]}, nil
+}
+
+// TraceContextFromRequest returns the context of a request continuing the trace of its traceparent and tracestate headers, or starting a new trace if the request carries no valid traceparent
+func TraceContextFromRequest(request *http.Request) *TraceContext {
+	parent, err := ParseTraceparent(request.Header.Get(TraceparentHeader))
+	if err != nil {
+		return NewTraceContext()
+	}
+	parent.SpanID = randomHex(8)
+	if state := strings.Join(request.Header[http.CanonicalHeaderKey(TracestateHeader)], ","); len(state) <= maxTracestateSize {
+		parent.State = state
+	}
+	return parent
+}
+
+// Traceparent renders the traceparent header propagating the trace to outbound requests, the span of the service being their parent
+func (t *TraceContext) Traceparent() string {
+	return fmt.Sprintf("%s-%s-%s-%02x", traceparentVersion, t.TraceID, t.SpanID, t.Flags)
+}
+
+// Sampled returns true if the caller may have recorded the trace
+func (t *TraceContext) Sampled() bool {
+	return t.Flags&traceFlagSampled != 0
+}
+
+// ContextWithTrace returns a context carrying a trace context
+func ContextWithTrace(ctx context.Context, trace *TraceContext) context.Context {
+	return context.WithValue(ctx, traceContextKey{}, trace)
+}
+
+// TraceFromContext returns the trace context carried by a context, or false if there is none. The RestfulLoggingFilter stores the trace context in the context of each request.
+func TraceFromContext(ctx context.Context) (*TraceContext, bool) {
+	if ctx == nil {
+		return nil, false
+	}
+	trace, ok := ctx.Value(traceContextKey{}).(*TraceContext)
+	return trace, ok
+}
+
+// InjectTraceContext sets the traceparent and tracestate headers of an outbound request from the trace context of a context, if any
+func InjectTraceContext(ctx context.Context, header http.Header) {
+	trace, ok := TraceFromContext(ctx)
+	if !ok {
+		return
+	}
+	header.Set(TraceparentHeader, trace.Traceparent())
+	if trace.State != "" {
+		header.Set(TracestateHeader, trace.State)
+	} else {
+		header.Del(TracestateHeader)
+	}
+}
+
+// NewTracedRequest initializes an outbound request propagating the trace context of a context, ie the context of the request being handled
+func NewTracedRequest(ctx context.Context, method string, url string, body io.Reader) (*http.Request, error) {
+	request, err := http.NewRequestWithContext(ctx, method, url, body)
+	if err != nil {
+		return nil, err
+	}
+	InjectTraceContext(ctx, request.Header)
+	return request, nil
+}
+
+// TracingTransport is an http.RoundTripper propagating the trace context of each request context, so that clients only need to pass the context of the request being handled
+type TracingTransport struct {
+	// Base is the transport sending the requests, defaulting to http.DefaultTransport
+	Base http.RoundTripper
+}
+
+// NewTracingTransport initializes a new transport wrapping a base transport
+func NewTracingTransport(base http.RoundTripper) *TracingTransport {
+	return &TracingTransport{Base: base}
+}
+
+// RoundTrip sends a copy of the request carrying the trace context headers, leaving the original request untouched
+func (t *TracingTransport) RoundTrip(request *http.Request) (*http.Response, error) {
+	base := t.Base
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	if _, ok := TraceFromContext(request.Context()); !ok {
+		return base.RoundTrip(request)
+	}
+	traced := request.Clone(request.Context())
+	InjectTraceContext(request.Context(), traced.Header)
+	return base.RoundTrip(traced)
+}
+
+func randomHex(size int) string {
+	id := make([]byte, size)
+	for {
+		if _, err := rand.Read(id); err != nil {
+			panic(err)
+		}
+		// all zero ids are invalid
+		for _, b := range id {
+			if b != 0 {
+				return hex.EncodeToString(id)
+			}
+		}
+	}
+}
+
+func isLowerHex(value string, size int) bool {
+	if len(value) != size {
+		return false
+	}
+	for _, c := range value {
+		if !(c >= '0' && c <= '9') && !(c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,257 @@
+// Copyright 2020 Daniel Akiva
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goserv
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/emicklei/go-restful"
+	"github.com/jmoiron/sqlx"
+	"github.com/stretchr/testify/assert"
+)
+
+const testTraceparent = "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"
+
+func TestParseTraceparent(t *testing.T) {
+	// when
+	trace, err := ParseTraceparent(testTraceparent)
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", trace.TraceID)
+	assert.Equal(t, "00f067aa0ba902b7", trace.ParentSpanID)
+	assert.True(t, trace.Sampled())
+}
+
+func TestParseTraceparentFutureVersion(t *testing.T) {
+	// when
+	trace, err := ParseTraceparent("cc-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00-future")
+
+	// then
+	assert.NoError(t, err)
+	assert.False(t, trace.Sampled())
+}
+
+func TestInvalidTraceparent(t *testing.T) {
+	// given
+	values := []string{
+		"",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra",
+		"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+		"00-00000000000000000000000000000000-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-0000000000000000-01",
+		"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01",
+		"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-1",
+	}
+
+	// when / then
+	for _, value := range values {
+		_, err := ParseTraceparent(value)
+		assert.Error(t, err, value)
+	}
+}
+
+func TestTraceContextFromRequest(t *testing.T) {
+	// given
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(TraceparentHeader, testTraceparent)
+	req.Header.Add(TracestateHeader, "congo=t61rcWkgMzE")
+	req.Header.Add(TracestateHeader, "rojo=00f067aa0ba902b7")
+
+	// when
+	trace := TraceContextFromRequest(req)
+
+	// then
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", trace.TraceID)
+	assert.Equal(t, "00f067aa0ba902b7", trace.ParentSpanID)
+	assert.Len(t, trace.SpanID, 16)
+	assert.NotEqual(t, trace.ParentSpanID, trace.SpanID)
+	assert.Equal(t, "congo=t61rcWkgMzE,rojo=00f067aa0ba902b7", trace.State)
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-"+trace.SpanID+"-01", trace.Traceparent())
+}
+
+func TestTraceContextFromRequestStartsTrace(t *testing.T) {
+	// given
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(TraceparentHeader, "invalid")
+	req.Header.Set(TracestateHeader, "congo=t61rcWkgMzE")
+
+	// when
+	trace := TraceContextFromRequest(req)
+
+	// then
+	_, err := ParseTraceparent(trace.Traceparent())
+	assert.NoError(t, err)
+	assert.Empty(t, trace.ParentSpanID)
+	assert.Empty(t, trace.State)
+	assert.True(t, trace.Sampled())
+}
+
+func TestRestfulLoggingFilterTrace(t *testing.T) {
+	// given
+	logger, buffer := newBufferedLogger("trace_context_test", NewJSONFormatter())
+	var handled *TraceContext
+	var attribute interface{}
+	ws := new(restful.WebService)
+	ws.Route(ws.GET("/traced").To(func(request *restful.Request, response *restful.Response) {
+		handled, _ = TraceFromContext(request.Request.Context())
+		attribute = request.Attribute(TraceIDAttribute)
+	}))
+	container := restful.NewContainer()
+	container.Filter(NewRestfulLoggingFilter(logger).Filter)
+	container.Add(ws)
+	req := httptest.NewRequest(http.MethodGet, "/traced", nil)
+	req.Header.Set(TraceparentHeader, testTraceparent)
+	recorder := httptest.NewRecorder()
+
+	// when
+	container.ServeHTTP(recorder, req)
+
+	// then
+	assert.NotNil(t, handled)
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", handled.TraceID)
+	assert.Equal(t, handled.TraceID, attribute)
+	assert.Equal(t, handled.TraceID, recorder.Header().Get(TraceIDResponseHeader))
+	for _, entry := range decodeLogLines(t, buffer) {
+		assert.Equal(t, handled.TraceID, entry["trace_id"])
+		assert.Equal(t, handled.SpanID, entry["span_id"])
+	}
+}
+
+func TestTracingTransport(t *testing.T) {
+	// given
+	var received http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header
+	}))
+	defer server.Close()
+	trace := &TraceContext{TraceID: "4bf92f3577b34da6a3ce929d0e0e4736", SpanID: "b7ad6b7169203331", Flags: 1, State: "congo=t61rcWkgMzE"}
+	client := &http.Client{Transport: NewTracingTransport(nil)}
+	req, _ := http.NewRequestWithContext(ContextWithTrace(context.Background(), trace), http.MethodGet, server.URL, nil)
+
+	// when
+	_, err := client.Do(req)
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, "00-4bf92f3577b34da6a3ce929d0e0e4736-b7ad6b7169203331-01", received.Get(TraceparentHeader))
+	assert.Equal(t, "congo=t61rcWkgMzE", received.Get(TracestateHeader))
+	assert.Empty(t, req.Header.Get(TraceparentHeader))
+}
+
+func TestNewTracedRequest(t *testing.T) {
+	// given
+	trace := NewTraceContext()
+
+	// when
+	traced, err := NewTracedRequest(ContextWithTrace(context.Background(), trace), http.MethodGet, "http://localhost/", nil)
+	untraced, _ := NewTracedRequest(context.Background(), http.MethodGet, "http://localhost/", nil)
+
+	// then
+	assert.NoError(t, err)
+	assert.Equal(t, trace.Traceparent(), traced.Header.Get(TraceparentHeader))
+	assert.Empty(t, untraced.Header.Get(TraceparentHeader))
+}
+
+// traceTestDriver is a database/sql driver accepting every statement, so that the database wrappers can be exercised without a database
+type traceTestDriver struct{}
+
+func (traceTestDriver) Open(name string) (driver.Conn, error) { return traceTestConn{}, nil }
+
+type traceTestConn struct{}
+
+func (traceTestConn) Prepare(query string) (driver.Stmt, error) { return traceTestStmt{}, nil }
+func (traceTestConn) Close() error                              { return nil }
+func (traceTestConn) Begin() (driver.Tx, error)                 { return traceTestTx{}, nil }
+
+type traceTestStmt struct{}
+
+func (traceTestStmt) Close() error  { return nil }
+func (traceTestStmt) NumInput() int { return -1 }
+func (traceTestStmt) Exec(args []driver.Value) (driver.Result, error) {
+	return driver.RowsAffected(1), nil
+}
+func (traceTestStmt) Query(args []driver.Value) (driver.Rows, error) {
+	return nil, errors.New("queries are not supported")
+}
+
+type traceTestTx struct{}
+
+func (traceTestTx) Commit() error   { return nil }
+func (traceTestTx) Rollback() error { return nil }
+
+func TestDBContextProviderLogsTrace(t *testing.T) {
+	// given
+	logger, buffer := newBufferedLogger("trace_db_test", NewJSONFormatter())
+	db := sqlx.NewDb(sql.OpenDB(driverConnector{traceTestDriver{}}), "postgres")
+	defer db.Close()
+	trace := NewTraceContext()
+	provider := DBContextProviderWithContext(NewDBContextProviderSQLXWrapper(db, true, logger), ContextWithTrace(context.Background(), trace))
+	arg := map[string]interface{}{"name": "ann"}
+
+	// when
+	dbContext, err := provider.GetContext()
+	assert.NoError(t, err)
+	_, execErr := dbContext.NamedExec("UPDATE accounts SET name = :name", arg)
+	_, queryErr := dbContext.NamedQuery("SELECT * FROM accounts WHERE name = :name", arg)
+	tx, err := provider.GetTxContext()
+	assert.NoError(t, err)
+	_, txErr := tx.NamedExec("UPDATE accounts SET name = :name", arg)
+	commitErr := tx.Commit()
+
+	// then
+	assert.NoError(t, execErr)
+	assert.Error(t, queryErr)
+	assert.NoError(t, txErr)
+	assert.NoError(t, commitErr)
+	entries := decodeLogLines(t, buffer)
+	assert.Len(t, entries, 5)
+	for _, entry := range entries {
+		assert.Equal(t, trace.TraceID, entry["trace_id"])
+		assert.Equal(t, trace.SpanID, entry["span_id"])
+		assert.True(t, strings.HasSuffix(entry["message"].(string), " trace="+trace.TraceID), entry["message"])
+	}
+}
+
+// driverConnector opens connections of a driver without registering a data source name
+type driverConnector struct {
+	driver driver.Driver
+}
+
+func (d driverConnector) Connect(ctx context.Context) (driver.Conn, error) { return d.driver.Open("") }
+func (d driverConnector) Driver() driver.Driver                            { return d.driver }
+
+func TestDBLogsTrace(t *testing.T) {
+	// given
+	logger, buffer := newBufferedLogger("trace_db_error_test", NewJSONFormatter())
+	trace := NewTraceContext()
+
+	// when
+	interpretDBError(errors.New("connection refused"), true, logger, trace)
+
+	// then
+	entries := decodeLogLines(t, buffer)
+	assert.Len(t, entries, 1)
+	assert.Equal(t, trace.TraceID, entries[0]["trace_id"])
+	assert.Equal(t, "[db error]:connection refused trace="+trace.TraceID, entries[0]["message"])
+}
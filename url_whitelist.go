@@ -64,20 +64,8 @@ func (u *URLWhiteList) Match(url string, requestMethod string) bool {
 	if methods, ok := u.urlMappings[url]; ok {
 		return len(methods) == 0 || methodMatch(methods, normalizedMethod)
 	}
-	urlValues := strings.Split(url, "/")
-	numValues := len(urlValues)
 	for k, methods := range u.urlMappings {
-		tokens := strings.Split(k, "/")
-		if numValues != len(tokens) {
-			continue
-		}
-		// loop until a segment is not matched, or all segments match
-		allMatch := true
-		for i := 0; i < numValues && allMatch; i++ {
-			// if a token sgement is a path variable, denoted by a : prefix, any url segment matches
-			allMatch = (strings.HasPrefix(tokens[i], u.Prefix) && strings.HasSuffix(tokens[i], u.Suffix)) || urlValues[i] == tokens[i]
-		}
-		if allMatch {
+		if segmentsMatch(k, url, u.Prefix, u.Suffix) {
 			// if no methods specified, accept any method
 			return len(methods) == 0 || methodMatch(methods, normalizedMethod)
 		}
@@ -85,6 +73,23 @@ func (u *URLWhiteList) Match(url string, requestMethod string) bool {
 	return false
 }
 
+// segmentsMatch returns true if the normalized url matches the normalized pattern segment by segment. Pattern segments wrapped in the prefix and suffix are path variables and match any value.
+func segmentsMatch(pattern string, url string, prefix string, suffix string) bool {
+	urlValues := strings.Split(url, "/")
+	numValues := len(urlValues)
+	tokens := strings.Split(pattern, "/")
+	if numValues != len(tokens) {
+		return false
+	}
+	// loop until a segment is not matched, or all segments match
+	allMatch := true
+	for i := 0; i < numValues && allMatch; i++ {
+		// if a token sgement is a path variable, denoted by a : prefix, any url segment matches
+		allMatch = (strings.HasPrefix(tokens[i], prefix) && strings.HasSuffix(tokens[i], suffix)) || urlValues[i] == tokens[i]
+	}
+	return allMatch
+}
+
 func methodMatch(requestMethods []string, requestMethod string) bool {
 	for _, m := range requestMethods {
 		if requestMethod == m {
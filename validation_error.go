@@ -0,0 +1,120 @@
+// Copyright 2020 Daniel Akiva
+
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+
+// http://www.apache.org/licenses/LICENSE-2.0
+
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package goserv
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+const (
+	// ErrorCodeValidationFailed is the error code of a ValidationError
+	ErrorCodeValidationFailed = "VALIDATION_FAILED"
+)
+
+func init() {
+	RegisterErrorCode(ErrorCodeValidationFailed, http.StatusBadRequest, "validation failed")
+}
+
+// FieldError represents a single invalid field of a request body
+type FieldError struct {
+	Path    string `json:"path" description:"A JSON pointer (RFC 6901) to the invalid field."`
+	Rule    string `json:"rule" description:"The validation rule that failed, ie required."`
+	Message string `json:"message" description:"A human readable description of the failure."`
+}
+
+// ValidationError represents a request body that failed validation on one or more fields (400). All field errors are rendered in the error response.
+type ValidationError struct {
+	FieldErrors []FieldError
+}
+
+// Error returns this error as a string
+func (v *ValidationError) Error() string {
+	messages := make([]string, len(v.FieldErrors))
+	for i, f := range v.FieldErrors {
+		messages[i] = fmt.Sprintf("%v: %v", f.Path, f.Message)
+	}
+	return fmt.Sprintf("validation failed: %v", strings.Join(messages, ", "))
+}
+
+// StatusCode returns the HTTP status code appropriate for the error type
+func (v *ValidationError) StatusCode() int { return http.StatusBadRequest }
+
+// ProblemType returns the RFC 7807 problem type name for the error type
+func (v *ValidationError) ProblemType() string { return "validation-failed" }
+
+// ErrorCode returns the application error code for the error type
+func (v *ValidationError) ErrorCode() string { return ErrorCodeValidationFailed }
+
+// PublicMessage returns the message shown to clients. The field errors are rendered separately.
+func (v *ValidationError) PublicMessage() string { return "validation failed" }
+
+// ValidationBuilder accumulates field errors so that a Validate implementation can report every invalid field at once.
+type ValidationBuilder struct {
+	fieldErrors []FieldError
+}
+
+// NewValidationBuilder initializes a new, empty builder
+func NewValidationBuilder() *ValidationBuilder {
+	return &ValidationBuilder{}
+}
+
+// Add records a field error
+func (v *ValidationBuilder) Add(path string, rule string, message string) *ValidationBuilder {
+	v.fieldErrors = append(v.fieldErrors, FieldError{Path: path, Rule: rule, Message: message})
+	return v
+}
+
+// Check records a field error if the condition does not hold
+func (v *ValidationBuilder) Check(condition bool, path string, rule string, message string) *ValidationBuilder {
+	if !condition {
+		v.Add(path, rule, message)
+	}
+	return v
+}
+
+// Merge records the field errors of a nested validation error with their paths prefixed. Errors other than a ValidationError are recorded at the prefix with an invalid rule.
+func (v *ValidationBuilder) Merge(prefix string, err error) *ValidationBuilder {
+	if err == nil {
+		return v
+	}
+	if validationErr, ok := err.(*ValidationError); ok {
+		for _, f := range validationErr.FieldErrors {
+			v.Add(prefix+f.Path, f.Rule, f.Message)
+		}
+		return v
+	}
+	return v.Add(prefix, "invalid", err.Error())
+}
+
+// Error returns a ValidationError holding the recorded field errors, or nil if none were recorded
+func (v *ValidationBuilder) Error() error {
+	if len(v.fieldErrors) == 0 {
+		return nil
+	}
+	return &ValidationError{FieldErrors: v.fieldErrors}
+}
+
+// JSONPointer builds an RFC 6901 JSON pointer from reference tokens, ie JSONPointer("items", 2, "name") returns /items/2/name
+func JSONPointer(tokens ...interface{}) string {
+	pointer := ""
+	for _, token := range tokens {
+		escaped := strings.ReplaceAll(fmt.Sprint(token), "~", "~0")
+		escaped = strings.ReplaceAll(escaped, "/", "~1")
+		pointer += "/" + escaped
+	}
+	return pointer
+}